@@ -0,0 +1,47 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+
+	"github.com/2charm/spectrum-api/pkg/users"
+	"github.com/2charm/spectrum-api/pkg/util"
+)
+
+//admin grants or revokes a role for an existing user, which is how the
+//first admin account is bootstrapped before any admin API can be used.
+//Sessions pick up the change when they next reload the user, and
+//access tokens when they're refreshed.
+//Usage: DSN=... admin -email someone@example.com [-role admin] [-revoke]
+func main() {
+	email := flag.String("email", "", "email of the user to update")
+	role := flag.String("role", users.RoleAdmin, "role to grant or revoke")
+	revoke := flag.Bool("revoke", false, "revoke the role instead of granting it")
+	flag.Parse()
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+	if !users.ValidRole(*role) {
+		log.Fatalf("unknown role %s", *role)
+	}
+	dsn := util.GetEnvironmentVariable("DSN")
+
+	//mySQL Server
+	db, err := sql.Open("mysql", dsn)
+	util.FailOnError(err, "Error opening a new SQL database")
+	err = db.Ping()
+	util.FailOnError(err, "Error pinging database")
+
+	ms := users.NewMySQLStore(db)
+	user, err := ms.GetByEmail(*email)
+	util.FailOnError(err, "Error finding user")
+
+	if *revoke {
+		util.FailOnError(ms.RemoveRole(user.ID, *role), "Error revoking role")
+		log.Printf("Revoked %s from %s", *role, user.UserName)
+		return
+	}
+	util.FailOnError(ms.AddRole(user.ID, *role), "Error granting role")
+	log.Printf("Granted %s to %s", *role, user.UserName)
+}
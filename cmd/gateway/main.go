@@ -2,92 +2,340 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/2charm/spectrum-api/pkg/util"
 
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/audit"
+	"github.com/2charm/spectrum-api/pkg/config"
+	"github.com/2charm/spectrum-api/pkg/exports"
 	"github.com/2charm/spectrum-api/pkg/handlers"
+	"github.com/2charm/spectrum-api/pkg/health"
+	"github.com/2charm/spectrum-api/pkg/identity"
+	"github.com/2charm/spectrum-api/pkg/logging"
+	"github.com/2charm/spectrum-api/pkg/metrics"
+	"github.com/2charm/spectrum-api/pkg/mtls"
+	"github.com/2charm/spectrum-api/pkg/ratelimit"
 	"github.com/2charm/spectrum-api/pkg/sessions"
+	"github.com/2charm/spectrum-api/pkg/tracing"
+	"github.com/2charm/spectrum-api/pkg/upstream"
 	"github.com/2charm/spectrum-api/pkg/users"
 	"github.com/go-redis/redis"
 )
 
 func main() {
-	addr := util.GetEnvironmentVariable("ADDR")
-	newsaddr := util.GetEnvironmentVariable("NEWSADDR")
-	tlscert := util.GetEnvironmentVariable("TLSCERT")
-	tlskey := util.GetEnvironmentVariable("TLSKEY")
-	sessionkey := util.GetEnvironmentVariable("SESSIONKEY")
-	redisaddr := util.GetEnvironmentVariable("REDISADDR")
-	dsn := util.GetEnvironmentVariable("DSN")
+	cfg := &config.Gateway{}
+	config.MustLoad("gateway", cfg, os.Args[1:])
+	util.FailOnError(logging.Setup("gateway", cfg.LogLevel), "Error setting up logging")
+
+	shutdownTracing, err := tracing.Setup("gateway", cfg.TraceExporter)
+	util.FailOnError(err, "Error setting up tracing")
+	tracing.FlushOnExit(shutdownTracing)
 
 	//Redis Server
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     redisaddr,
-		Password: "",
-		DB:       0,
-	})
-	_, err := rdb.Ping().Result()
+	rdb := redis.NewClient(cfg.Redis.Options())
+	_, err = rdb.Ping().Result()
 	util.FailOnError(err, "Error pinging redis database")
 
-	rs := sessions.NewRedisStore(rdb, time.Hour)
+	rs := sessions.NewInstrumentedStore(sessions.NewRedisStore(rdb, cfg.Session.Duration))
 
 	//mySQL Server
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open("mysql", cfg.MySQL.DSN)
 	util.FailOnError(err, "Error opening a new SQL database")
 
 	err = db.Ping()
 	util.FailOnError(err, "Error pinging database")
-	log.Printf("Successfully connected to SQL database!\n")
+	slog.Info("connected to SQL database")
 
 	ms := users.NewMySQLStore(db)
+	configurePasswords(&cfg.Passwords)
+	as := audit.NewMySQLStore(db)
 
-	ctx := handlers.HandlerContext{
-		SigningKey:   sessionkey,
-		SessionStore: rs,
-		UserStore:    ms,
+	sessionConfig := &sessions.Config{
+		Keys:                loadSessionKeys(&cfg.Session),
+		AccessTokenDuration: cfg.Session.AccessTokenDuration,
+		Algorithm:           cfg.Session.TokenAlg,
+		Mode:                sessions.ModeSession,
+		Transport:           sessions.TransportHeader,
+	}
+	if cfg.Session.Mode == "token" {
+		sessionConfig.Mode = sessions.ModeToken
+	}
+	if cfg.Session.Transport == "cookie" {
+		sessionConfig.Transport = sessions.TransportCookie
+		sessionConfig.CookieDomain = cfg.Session.CookieDomain
+		sessionConfig.SameSite = http.SameSiteStrictMode
+		if cfg.Session.CookieSameSite == "lax" {
+			sessionConfig.SameSite = http.SameSiteLaxMode
+		}
+	}
+	if cfg.Session.TokenAlg == sessions.AlgEdDSA {
+		sessionConfig.EdDSAKey = cfg.Session.EdDSAKey()
 	}
 
-	newsURL, err := url.Parse("http://" + newsaddr)
+	//the news service is called over mutual TLS when internal certificates are configured
+	newsScheme := "http"
+	newsTransport := http.DefaultTransport.(*http.Transport).Clone()
+	if certs := loadInternalCertificates(&cfg.Internal); certs != nil {
+		newsScheme = "https"
+		newsTransport.TLSClientConfig = certs.ClientConfig()
+	}
+	newsURL, err := url.Parse(newsScheme + "://" + cfg.News.Addr)
 	util.FailOnError(err, "Invalid URL for microservice")
+	newsBreaker := upstream.NewBreaker("news", cfg.News.BreakerFailures, cfg.News.BreakerCooldown)
+	newsRoundTripper := upstream.NewBreakerTransport(metrics.InstrumentProxy("news", newsTransport), newsBreaker)
+
+	//revocations are kept until every session and access token issued
+	//before them has reloaded its user
+	revocationTTL := cfg.Session.AccessTokenDuration
+	if revocationTTL < handlers.UserReloadInterval {
+		revocationTTL = handlers.UserReloadInterval
+	}
+
+	ctx := handlers.HandlerContext{
+		SessionConfig:  sessionConfig,
+		SessionStore:   rs,
+		Revocations:    sessions.NewRedisRevocations(rdb, revocationTTL),
+		UserStore:      ms,
+		AuditStore:     as,
+		NewsURL:        newsURL,
+		IdentitySigner: newIdentitySigner(&cfg.Identity),
+		NewsClient:     &http.Client{Timeout: cfg.News.Timeout, Transport: newsRoundTripper},
+		ExportStore:    exports.NewRedisStore(rdb, cfg.ExportTTL),
+		TOTPIssuer:     "Spectrum News",
+		MFADuration:    cfg.Session.MFADuration,
+	}
+
+	slog.Info("proxying to news service", "url", newsURL.String())
+	newsProxy := &httputil.ReverseProxy{
+		Director: customDirector(newsURL, &ctx),
+		//the gateway has already set the request ID on the response
+		ModifyResponse: func(resp *http.Response) error {
+			resp.Header.Del(apierror.HeaderRequestID)
+			return nil
+		},
+		Transport:    newsRoundTripper,
+		ErrorHandler: proxyErrorHandler,
+	}
 
-	log.Printf("News Microservice URL: %s", newsURL.String())
-	newsProxy := &httputil.ReverseProxy{Director: customDirector(newsURL, &ctx)}
+	readyURL := *newsURL
+	readyURL.Path = "/readyz"
+	checker := health.NewChecker(time.Second*5,
+		&health.Check{Name: "mysql", Critical: true, Check: health.SQL(db)},
+		&health.Check{Name: "redis", Critical: true, Check: health.Redis(rdb)},
+		&health.Check{Name: "news", Critical: true, Check: health.Service(ctx.NewsClient, readyURL.String())},
+	)
 
 	mux := http.NewServeMux()
-	mux.Handle("/v1/news", newsProxy)                           //Get news
-	mux.Handle("/v1/spectrum/", newsProxy)                      //Get related news
-	mux.Handle("/v1/metrics", newsProxy)                        //Get and post metrics
-	mux.HandleFunc("/v1/users", ctx.UsersHandler)               //Create user
-	mux.HandleFunc("/v1/sessions", ctx.SessionsHandler)         //Login user
-	mux.HandleFunc("/v1/sessions/", ctx.SpecificSessionHandler) //Logout user
-	wrappedMux := handlers.NewResponseHeader(mux)
-	log.Printf("server is listening at %s...", addr)
-	log.Fatal(http.ListenAndServeTLS(addr, tlscert, tlskey, wrappedMux))
+	mux.HandleFunc("/healthz", health.LivenessHandler)                      //Report the gateway is running
+	mux.HandleFunc("/readyz", checker.ReadinessHandler)                     //Report the state of each dependency
+	mux.Handle("/v1/news", newsProxy)                                       //Get news
+	mux.Handle("/v1/spectrum/", newsProxy)                                  //Get related news
+	mux.Handle("/v1/metrics", newsProxy)                                    //Get and post metrics
+	mux.Handle("/v1/metrics/history", newsProxy)                            //Get reading history
+	mux.Handle("/v1/collections", newsProxy)                                //List and create collections
+	mux.Handle("/v1/collections/", newsProxy)                               //Get, edit and share a collection
+	mux.Handle("/v1/public/collections/", newsProxy)                        //Read a public collection without a session
+	mux.HandleFunc("/v1/users", ctx.UsersHandler)                           //Create user
+	mux.HandleFunc("/v1/users/available", ctx.AvailableHandler)             //Check user name and email availability
+	mux.HandleFunc("/v1/users/search", ctx.UserSearchHandler)               //Search public profiles by prefix
+	mux.HandleFunc("/v1/users/me/following", ctx.FollowingHandler)          //List followed users
+	mux.HandleFunc("/v1/users/me/following/", ctx.SpecificFollowingHandler) //Follow or unfollow a user
+	mux.HandleFunc("/v1/users/me/followers", ctx.FollowersHandler)          //List followers
+	mux.HandleFunc("/v1/users/me/privacy", ctx.PrivacyHandler)              //Get and set privacy settings
+	mux.HandleFunc("/v1/users/me/feed", ctx.FeedHandler)                    //Get reading activity of followed users
+	mux.HandleFunc("/v1/users/me/totp", ctx.TOTPHandler)                    //Enroll, confirm and disable two-factor
+	mux.HandleFunc("/v1/users/me/password", ctx.PasswordHandler)            //Change password
+	mux.HandleFunc("/v1/users/me/export", ctx.ExportHandler)                //Export account data
+	mux.HandleFunc("/v1/users/me/exports/", ctx.SpecificExportHandler)      //Poll or download an export
+	mux.HandleFunc("/v1/sessions", ctx.SessionsHandler)                     //Login user and list active sessions
+	mux.HandleFunc("/v1/sessions/mfa", ctx.MFAHandler)                      //Complete two-factor login
+	mux.HandleFunc("/v1/sessions/refresh", ctx.RefreshHandler)              //Rotate refresh token
+	mux.HandleFunc("/v1/sessions/", ctx.SpecificSessionHandler)             //Logout current, one or all sessions
+
+	//Admin APIs
+	adminRoles := []string{users.RoleAdmin, users.RoleModerator}
+	adminUsers := ctx.RequireRole(http.HandlerFunc(ctx.AdminUsersHandler), adminRoles...)
+	adminUser := ctx.RequireRole(http.HandlerFunc(ctx.AdminSpecificUserHandler), adminRoles...)
+	adminAudit := ctx.RequireRole(http.HandlerFunc(ctx.AdminAuditHandler), users.RoleAdmin)
+	adminNewsAPI := ctx.RequireRole(newsProxy, users.RoleAdmin)
+	mux.Handle("/v1/admin/users", adminUsers)     //List and search users
+	mux.Handle("/v1/admin/users/", adminUser)     //Suspend, reset password or impersonate a user
+	mux.Handle("/v1/admin/audit", adminAudit)     //Read the audit trail
+	mux.Handle("/v1/admin/newsapi", adminNewsAPI) //Get today's NewsAPI usage
+
+	//Rate limits, the first rule matching a request applies
+	post := []string{"POST"}
+	limitedMux := handlers.NewRateLimiter(mux, newRateLimitStore(cfg.RateLimitStore, rdb), &ctx,
+		&handlers.RateLimitRule{Path: "/v1/users", Methods: post, Key: handlers.KeyByIP, Policy: ratelimit.NewPolicy("signup", 5, time.Hour)},
+		&handlers.RateLimitRule{Path: "/v1/sessions", Methods: post, Key: handlers.KeyByIP, Policy: ratelimit.NewPolicy("signin", 10, time.Minute)},
+		&handlers.RateLimitRule{Path: "/v1/sessions/mfa", Methods: post, Key: handlers.KeyByIP, Policy: ratelimit.NewPolicy("mfa", 10, time.Minute)},
+		&handlers.RateLimitRule{Path: "/v1/users/me/password", Key: handlers.KeyByUser, Policy: ratelimit.NewPolicy("password", 5, time.Minute)},
+		&handlers.RateLimitRule{Path: "/v1/users/available", Key: handlers.KeyByIP, Policy: ratelimit.NewPolicy("available", 30, time.Minute)},
+		&handlers.RateLimitRule{Path: "/v1/users/search", Key: handlers.KeyByUser, Policy: ratelimit.NewPolicy("search", 60, time.Minute)},
+		&handlers.RateLimitRule{Path: "/v1/spectrum/", Key: handlers.KeyByUser, Policy: ratelimit.NewPolicy("spectrum", 20, time.Minute)},
+		&handlers.RateLimitRule{Path: "/v1/news", Key: handlers.KeyByUser, Policy: ratelimit.NewPolicy("news", 60, time.Minute)},
+		&handlers.RateLimitRule{Path: "/", Key: handlers.KeyByUser, Policy: ratelimit.NewPolicy("default", 300, time.Minute)},
+	)
+
+	//the rate limiter, handlers and proxy share each request's session state
+	resolvedMux := ctx.ResolveSession(limitedMux)
+	wrappedMux := handlers.NewResponseHeader(resolvedMux)
+	if sessionConfig.Transport == sessions.TransportCookie {
+		wrappedMux = handlers.NewCredentialedResponseHeader(resolvedMux, cfg.Session.CORSOrigin)
+	}
+	go func() {
+		util.FailOnError(metrics.ListenAndServe(cfg.MetricsAddr), "Error serving metrics")
+	}()
+	slog.Info("serving metrics", "addr", cfg.MetricsAddr)
+	slog.Info("server is listening", "addr", cfg.Addr)
+	handler := logging.NewRequestID(tracing.NewHTTPTracing(logging.NewAccessLog(metrics.NewHTTPMetrics(wrappedMux, mux)), mux))
+	server := cfg.Server.NewServer(cfg.Addr, handler)
+	util.FailOnError(server.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey), "Error serving")
 }
 
 func customDirector(target *url.URL, ctx *handlers.HandlerContext) func(*http.Request) {
 	return func(r *http.Request) {
-		sessState := &handlers.SessionState{}
-		log.Print("Accessing session...")
-		_, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, sessState)
+		//the user is reloaded once revoked, so suspended users aren't asserted
+		user, err := ctx.AuthenticatedUser(r)
+
+		//the news service only trusts the signed X-User assertion, so never
+		//forward client credentials or a client's own X-User header
+		for _, header := range []string{identity.HeaderUser, "Authorization", "Cookie", "Refresh-Token", sessions.HeaderCSRF} {
+			r.Header.Del(header)
+		}
+		if query := r.URL.Query(); query.Get("auth") != "" {
+			query.Del("auth")
+			r.URL.RawQuery = query.Encode()
+		}
+		//the request ID was set by the RequestID middleware
+		requestID := r.Header.Get(apierror.HeaderRequestID)
+
 		if err == nil {
-			obj, err := json.Marshal(sessState.User)
+			assertion, err := ctx.IdentitySigner.Sign(user, requestID)
 			if err == nil {
-				log.Print("Valid User!")
-				r.Header.Del("X-User")
-				r.Header.Add("X-User", string(obj))
+				r.Header.Set(identity.HeaderUser, assertion)
+			} else {
+				slog.ErrorContext(r.Context(), "error signing user assertion", "error", err)
 			}
 		} else {
-			log.Printf("Error getting state: %v", err)
+			slog.DebugContext(r.Context(), "proxying without a user", "error", err)
 		}
+		//continue the trace in the news service
+		tracing.Inject(r.Context(), r.Header)
 		r.Host = target.Host
 		r.URL.Host = target.Host
 		r.URL.Scheme = target.Scheme
 	}
 }
+
+//proxyErrorHandler responds to requests the news service couldn't serve.
+//While the news breaker is open clients are told when to retry.
+func proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	openErr := &upstream.OpenError{}
+	if errors.As(err, &openErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(openErr.RetryAfter.Seconds())+1))
+		apierror.Write(w, r, apierror.Unavailable("news service is unavailable, try again later", err))
+		return
+	}
+	apierror.Write(w, r, apierror.Upstream("error reaching news service", err))
+}
+
+//newIdentitySigner constructs the signer of the user assertions sent to the
+//news service, using Ed25519 if a seed is configured and the shared HMAC
+//key otherwise
+func newIdentitySigner(cfg *config.Signer) *identity.Signer {
+	if key := cfg.EdDSAKey(); key != nil {
+		return identity.NewEd25519Signer(key, cfg.TTL)
+	}
+	return identity.NewHMACSigner([]byte(cfg.Key), cfg.TTL)
+}
+
+//newRateLimitStore returns the store of rate limit buckets, either redis,
+//shared by every gateway instance, or memory
+func newRateLimitStore(store string, rdb *redis.Client) ratelimit.Store {
+	if store == "memory" {
+		return ratelimit.NewMemStore(time.Minute * 10)
+	}
+	return ratelimit.NewRedisStore(rdb)
+}
+
+//loadInternalCertificates loads the gateway's client certificate and key,
+//and the CA that signs the news service's certificate. The files are watched
+//for rotated certificates. It returns nil if mutual TLS isn't configured.
+func loadInternalCertificates(cfg *config.Internal) *mtls.Certificates {
+	if !cfg.Enabled() {
+		return nil
+	}
+	certs, err := mtls.Load(cfg.Cert, cfg.Key, cfg.CA)
+	util.FailOnError(err, "Error loading internal certificates")
+	go func() {
+		for range time.Tick(time.Minute) {
+			reloaded, err := certs.Reload()
+			if err != nil {
+				slog.Error("error reloading internal certificates", "error", err)
+			} else if reloaded {
+				slog.Info("reloaded internal certificates")
+			}
+		}
+	}()
+	return certs
+}
+
+//loadSessionKeys loads the session signing keys from the key file, which is
+//watched for rotated keys, from the key ring, or from the single key
+func loadSessionKeys(cfg *config.Session) *sessions.KeyRing {
+	if cfg.KeyFile != "" {
+		keys, err := sessions.LoadKeyRing(cfg.KeyFile)
+		util.FailOnError(err, "Error loading session keys")
+		go func() {
+			for range time.Tick(time.Minute) {
+				reloaded, err := keys.Reload()
+				if err != nil {
+					slog.Error("error reloading session keys", "error", err)
+				} else if reloaded {
+					slog.Info("reloaded session keys", "current", keys.Current().ID)
+				}
+			}
+		}()
+		return keys
+	}
+	if cfg.Keys != "" {
+		keys, err := sessions.ParseKeyRing(cfg.Keys)
+		util.FailOnError(err, "Error parsing session keys")
+		return keys
+	}
+	keys, err := sessions.NewKeyRing(&sessions.Key{
+		ID:     "default",
+		Secret: []byte(cfg.Key),
+	})
+	util.FailOnError(err, "Error creating session key ring")
+	return keys
+}
+
+//configurePasswords sets the password hasher, bcrypt cost and password
+//policy. The breached password list is a hash file or a directory of
+//SHA-1 prefix range files.
+func configurePasswords(cfg *config.Passwords) {
+	hasher, err := users.PasswordHasher(cfg.Hasher)
+	util.FailOnError(err, "Invalid password hasher")
+	users.SetPasswordHasher(hasher)
+	util.FailOnError(users.SetBcryptCost(cfg.BcryptCost), "Invalid bcrypt cost")
+	policy := &users.PasswordPolicy{
+		MinLength: cfg.MinLength,
+		MinScore:  cfg.MinScore,
+	}
+	if cfg.Breached != "" {
+		policy.Breached, err = users.LoadBreachedList(cfg.Breached)
+		util.FailOnError(err, "Error loading breached passwords")
+	}
+	users.SetPasswordPolicy(policy)
+}
@@ -2,41 +2,122 @@ package main
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
 	cache "github.com/patrickmn/go-cache"
 
+	"github.com/2charm/spectrum-api/pkg/config"
+	"github.com/2charm/spectrum-api/pkg/health"
+	"github.com/2charm/spectrum-api/pkg/identity"
+	"github.com/2charm/spectrum-api/pkg/logging"
+	"github.com/2charm/spectrum-api/pkg/metrics"
+	"github.com/2charm/spectrum-api/pkg/mtls"
 	"github.com/2charm/spectrum-api/pkg/news"
+	"github.com/2charm/spectrum-api/pkg/tracing"
+	"github.com/2charm/spectrum-api/pkg/upstream"
 	"github.com/2charm/spectrum-api/pkg/util"
 )
 
 func main() {
-	addr := util.GetEnvironmentVariable("ADDR")
-	apikey := util.GetEnvironmentVariable("APIKEY")
-	dsn := util.GetEnvironmentVariable("DSN")
+	cfg := &config.NewsService{}
+	config.MustLoad("news", cfg, os.Args[1:])
+	util.FailOnError(logging.Setup("news", cfg.LogLevel), "Error setting up logging")
+
+	shutdownTracing, err := tracing.Setup("news", cfg.TraceExporter)
+	util.FailOnError(err, "Error setting up tracing")
+	tracing.FlushOnExit(shutdownTracing)
 
 	//mySQL Server
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open("mysql", cfg.MySQL.DSN)
 	util.FailOnError(err, "Error opening a new SQL database")
 	err = db.Ping()
 	util.FailOnError(err, "Error pinging database")
-	log.Printf("Successfully connected to SQL database!\n")
+	slog.Info("connected to SQL database")
 
 	as := news.NewArticleStore(db)
+	newsAPI := upstream.NewClient(cfg.NewsAPI.Timeout)
+	newsAPI.Retryable = news.RetryableResponse
 
 	ctx := news.HandlerContext{
-		APIKey:       apikey,
-		ArticleStore: as,
-		ArticleCache: cache.New(time.Minute*15, time.Minute*25),
+		APIKey:             cfg.NewsAPI.Key,
+		ArticleStore:       as,
+		CollectionStore:    as,
+		ArticleCache:       cache.New(time.Minute*15, time.Minute*25),
+		TopArticlesTTL:     cfg.Cache.TopArticlesTTL,
+		RelatedArticlesTTL: cfg.Cache.RelatedArticlesTTL,
+		StaleFor:           cfg.Cache.StaleFor,
+		Quota:              news.NewQuota(cfg.NewsAPI.Budget, cfg.NewsAPI.Reserved),
+		NewsAPI:            newsAPI,
+		IdentityVerifier:   newIdentityVerifier(&cfg.Identity),
 	}
 
+	checker := health.NewChecker(time.Second*5,
+		&health.Check{Name: "mysql", Critical: true, Check: health.SQL(db)},
+		&health.Check{Name: "newsapi", Check: ctx.CheckNewsAPI},
+	)
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/v1/news", ctx.NewsHandler)          //Get news
-	mux.HandleFunc("/v1/spectrum/", ctx.SpectrumHandler) //Get full spectrum of news
-	mux.HandleFunc("/v1/metrics", ctx.MetricsHandler)    //Get and post metrics
+	mux.HandleFunc("/healthz", health.LivenessHandler)                     //Report the service is running
+	mux.HandleFunc("/readyz", checker.ReadinessHandler)                    //Report the state of each dependency
+	mux.HandleFunc("/v1/news", ctx.NewsHandler)                            //Get news
+	mux.HandleFunc("/v1/spectrum/", ctx.SpectrumHandler)                   //Get full spectrum of news
+	mux.HandleFunc("/v1/metrics", ctx.MetricsHandler)                      //Get and post metrics
+	mux.HandleFunc("/v1/metrics/history", ctx.HistoryHandler)              //Get reading history
+	mux.HandleFunc("/v1/metrics/feed", ctx.FeedHandler)                    //Get reading activity of followed users
+	mux.HandleFunc("/v1/collections", ctx.CollectionsHandler)              //List and create collections
+	mux.HandleFunc("/v1/collections/", ctx.SpecificCollectionHandler)      //Get, edit and share a collection
+	mux.HandleFunc("/v1/public/collections/", ctx.PublicCollectionHandler) //Read a public collection
+	mux.HandleFunc("/v1/admin/newsapi", ctx.QuotaHandler)                  //Get today's NewsAPI usage
+
+	go func() {
+		util.FailOnError(metrics.ListenAndServe(cfg.MetricsAddr), "Error serving metrics")
+	}()
+	slog.Info("serving metrics", "addr", cfg.MetricsAddr)
+
+	handler := logging.NewRequestID(tracing.NewHTTPTracing(logging.NewAccessLog(metrics.NewHTTPMetrics(mux, mux)), mux))
+	server := cfg.Server.NewServer(cfg.Addr, handler)
+	certs := loadInternalCertificates(&cfg.Internal)
+	if certs == nil {
+		slog.Info("server is listening", "addr", cfg.Addr)
+		util.FailOnError(server.ListenAndServe(), "Error serving")
+	}
+	//only the gateway holds a client certificate signed by the internal CA
+	server.TLSConfig = certs.ServerConfig()
+	slog.Info("server is listening with mutual TLS", "addr", cfg.Addr)
+	util.FailOnError(server.ListenAndServeTLS("", ""), "Error serving")
+}
 
-	log.Printf("server is listening at %s...", addr)
-	log.Fatal(http.ListenAndServe(addr, mux))
+//loadInternalCertificates loads the service's certificate and key, and the
+//CA that signs the gateway's client certificate. The files are watched for
+//rotated certificates. It returns nil if mutual TLS isn't configured.
+func loadInternalCertificates(cfg *config.Internal) *mtls.Certificates {
+	if !cfg.Enabled() {
+		return nil
+	}
+	certs, err := mtls.Load(cfg.Cert, cfg.Key, cfg.CA)
+	util.FailOnError(err, "Error loading internal certificates")
+	go func() {
+		for range time.Tick(time.Minute) {
+			reloaded, err := certs.Reload()
+			if err != nil {
+				slog.Error("error reloading internal certificates", "error", err)
+			} else if reloaded {
+				slog.Info("reloaded internal certificates")
+			}
+		}
+	}()
+	return certs
+}
+
+//newIdentityVerifier constructs the verifier of the user assertions signed
+//by the gateway, using Ed25519 if a public key is configured and the
+//shared HMAC key otherwise
+func newIdentityVerifier(cfg *config.Verifier) *identity.Verifier {
+	if key := cfg.EdDSAPublicKey(); key != nil {
+		return identity.NewEd25519Verifier(key, cfg.Skew)
+	}
+	return identity.NewHMACVerifier([]byte(cfg.Key), cfg.Skew)
 }
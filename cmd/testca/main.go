@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/util"
+)
+
+//testca generates a local CA with a server certificate for the news service
+//and a client certificate for the gateway, for testing mutual TLS between
+//them. The keys are written unencrypted, so don't use them in production.
+//Usage: testca [-out ./certs] [-hosts news_service,localhost] [-days 30]
+func main() {
+	out := flag.String("out", ".", "directory to write the certificates and keys to")
+	hosts := flag.String("hosts", "news_service,localhost,127.0.0.1", "comma separated names and IPs of the news service")
+	days := flag.Int("days", 30, "number of days the certificates are valid")
+	flag.Parse()
+	util.FailOnError(os.MkdirAll(*out, 0700), "Error creating output directory")
+	validFor := time.Duration(*days) * time.Hour * 24
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	util.FailOnError(err, "Error generating CA key")
+	caTemplate := newTemplate("Spectrum Test CA", validFor)
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	util.FailOnError(err, "Error creating CA certificate")
+	caCert, err := x509.ParseCertificate(caDER)
+	util.FailOnError(err, "Error parsing CA certificate")
+	write(*out, "ca", caDER, caKey)
+
+	server := newTemplate("news_service", validFor)
+	server.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range strings.Split(*hosts, ",") {
+		if ip := net.ParseIP(host); ip != nil {
+			server.IPAddresses = append(server.IPAddresses, ip)
+		} else if host != "" {
+			server.DNSNames = append(server.DNSNames, host)
+		}
+	}
+	issue(*out, "news", server, caCert, caKey)
+
+	client := newTemplate("gateway", validFor)
+	client.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	issue(*out, "gateway", client, caCert, caKey)
+
+	log.Printf("Wrote ca.pem, ca-key.pem, news.pem, news-key.pem, gateway.pem and gateway-key.pem to %s", *out)
+}
+
+//newTemplate returns a certificate template with a random serial number
+func newTemplate(commonName string, validFor time.Duration) *x509.Certificate {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	util.FailOnError(err, "Error generating serial number")
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Spectrum News"}},
+		NotBefore:    now.Add(-time.Minute * 5),
+		NotAfter:     now.Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+//issue generates a key and a certificate signed by the CA, and writes them
+func issue(out string, name string, template *x509.Certificate, ca *x509.Certificate, caKey crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	util.FailOnError(err, "Error generating key for "+name)
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	util.FailOnError(err, "Error creating certificate for "+name)
+	write(out, name, der, key)
+}
+
+//write writes the certificate to <name>.pem and the key to <name>-key.pem
+func write(out string, name string, der []byte, key *ecdsa.PrivateKey) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	util.FailOnError(err, "Error encoding key for "+name)
+	writePEM(filepath.Join(out, name+".pem"), "CERTIFICATE", der, 0644)
+	writePEM(filepath.Join(out, name+"-key.pem"), "PRIVATE KEY", keyDER, 0600)
+}
+
+//writePEM writes the PEM encoding of the DER bytes to the file at path
+func writePEM(path string, blockType string, der []byte, perm os.FileMode) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	util.FailOnError(err, "Error creating "+path)
+	defer f.Close()
+	util.FailOnError(pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}), "Error writing "+path)
+}
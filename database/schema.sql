@@ -1,16 +1,56 @@
 create table if not exists users (
     user_id int not null auto_increment primary key,
     email varchar(128) not null unique,
+    -- PHC string or bcrypt hash, the prefix names the algorithm
     pass_hash varchar(256) not null,
     user_name varchar(256) not null unique,
     first_name varchar(64) not null,
-    last_name varchar(128) not null
+    last_name varchar(128) not null,
+    totp_secret varchar(64) not null default '',
+    totp_enabled boolean not null default false,
+    -- time step of the last accepted TOTP code, so codes can't be replayed
+    totp_last_step bigint not null default 0,
+    suspended boolean not null default false,
+    password_reset_required boolean not null default false,
+    share_activity boolean not null default false,
+    -- when share_activity was last turned on, only reading since is shared
+    share_activity_since datetime null
+);
+
+create table if not exists follows (
+    follower_id int not null,
+    followee_id int not null,
+    created_at datetime not null,
+    primary key (follower_id, followee_id),
+    index (followee_id)
+);
+
+create table if not exists recovery_codes (
+    user_id int not null,
+    code_hash char(64) not null,
+    primary key (user_id, code_hash)
+);
+
+create table if not exists user_roles (
+    user_id int not null,
+    role_name varchar(32) not null,
+    primary key (user_id, role_name)
+);
+
+create table if not exists audit_log (
+    audit_id int not null auto_increment primary key,
+    actor_id int not null,
+    action varchar(64) not null,
+    target_id int not null,
+    detail varchar(1024) not null,
+    created_at datetime not null
 );
 
 create table if not exists sign_in (
     user_id int not null,
     attempt_time datetime not null,
-    client_ip varchar(128) not null
+    client_ip varchar(128) not null,
+    succeeded boolean not null default true
 );
 
 create table if not exists categories (
@@ -44,3 +84,31 @@ create table if not exists sources (
     source_id int not null auto_increment primary key,
     source_name varchar(128) not null unique
 );
+
+create table if not exists collections (
+    collection_id int not null auto_increment primary key,
+    slug varchar(64) not null unique,
+    name varchar(128) not null,
+    description varchar(1024) not null default '',
+    owner_id int not null,
+    is_public boolean not null default false,
+    created_at datetime not null,
+    updated_at datetime not null,
+    index (owner_id)
+);
+
+create table if not exists collection_collaborators (
+    collection_id int not null,
+    user_id int not null,
+    primary key (collection_id, user_id),
+    index (user_id)
+);
+
+create table if not exists collection_articles (
+    collection_id int not null,
+    position int not null,
+    article_url varchar(512) not null,
+    -- the news.Article as JSON
+    article text not null,
+    primary key (collection_id, position)
+);
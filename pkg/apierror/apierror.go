@@ -0,0 +1,221 @@
+package apierror
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+//HeaderRequestID is the header carrying the ID of the request
+const HeaderRequestID = "X-Request-ID"
+
+//contentType is the media type of problem responses, from RFC 7807
+const contentType = "application/problem+json"
+
+//Code identifies the kind of error so clients can branch on it
+type Code string
+
+//Error codes shared by the gateway and news service
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeValidation           Code = "validation_failed"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeUnauthenticated      Code = "unauthenticated"
+	CodeInvalidCredentials   Code = "invalid_credentials"
+	CodeInvalidMFACode       Code = "invalid_mfa_code"
+	CodeForbidden            Code = "forbidden"
+	CodeAccountSuspended     Code = "account_suspended"
+	CodeNotFound             Code = "not_found"
+	CodeConflict             Code = "conflict"
+	CodeTooManyRequests      Code = "too_many_requests"
+	CodeInternal             Code = "internal_error"
+	CodeUpstream             Code = "upstream_error"
+	CodeUnavailable          Code = "service_unavailable"
+)
+
+//Error is an error that maps to an HTTP status and error code.
+//Only Message and Fields are shown to clients; the wrapped error
+//is logged instead, so internal details don't leak.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	//Fields are extra members added to the problem, such as validation reasons
+	Fields map[string]interface{}
+	//Err is the underlying error, if any
+	Err error
+}
+
+//Error returns the message and the underlying error, if any
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+//Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+//With returns a copy of the error with an extra member in its problem
+func (e *Error) With(key string, value interface{}) *Error {
+	copied := *e
+	copied.Fields = map[string]interface{}{}
+	for k, v := range e.Fields {
+		copied.Fields[k] = v
+	}
+	copied.Fields[key] = value
+	return &copied
+}
+
+//New constructs an Error
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+//BadRequest returns a 400 error
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+//UnsupportedMediaType returns a 415 error for request bodies that aren't JSON
+func UnsupportedMediaType() *Error {
+	return New(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "request body must be of type JSON")
+}
+
+//MethodNotAllowed returns a 405 error
+func MethodNotAllowed() *Error {
+	return New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "incompatible http method")
+}
+
+//Unauthenticated returns a 401 error
+func Unauthenticated(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthenticated, message)
+}
+
+//Forbidden returns a 403 error
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+//NotFound returns a 404 error
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+//Conflict returns a 409 error
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+//TooManyRequests returns a 429 error
+func TooManyRequests(message string) *Error {
+	return New(http.StatusTooManyRequests, CodeTooManyRequests, message)
+}
+
+//Internal returns a 500 error wrapping the underlying error, which is
+//logged when the error is written but never sent to the client
+func Internal(message string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Err: err}
+}
+
+//Upstream returns a 502 error for failures of a service we depend on
+func Upstream(message string, err error) *Error {
+	return &Error{Status: http.StatusBadGateway, Code: CodeUpstream, Message: message, Err: err}
+}
+
+//Unavailable returns a 503 error for requests that can't be served until later
+func Unavailable(message string, err error) *Error {
+	return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: message, Err: err}
+}
+
+//Problem is an RFC 7807 problem details object
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"requestID"`
+}
+
+//Write writes the error as a problem response. Errors that aren't
+//an *Error are written as internal errors without their details.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, ok := err.(*Error)
+	if !ok {
+		apiErr = Internal("internal error", err)
+	}
+	requestID := RequestID(w, r)
+	if apiErr.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path,
+			"status", apiErr.Status, "error", apiErr)
+	}
+
+	problem := &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(apiErr.Status),
+		Status:    apiErr.Status,
+		Detail:    apiErr.Message,
+		Instance:  r.URL.Path,
+		Code:      apiErr.Code,
+		RequestID: requestID,
+	}
+	body, err := marshalProblem(problem, apiErr.Fields)
+	if err != nil {
+		http.Error(w, "error marshaling JSON", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	w.Write(body)
+}
+
+//marshalProblem encodes the problem with the extra fields as top-level members
+func marshalProblem(problem *Problem, fields map[string]interface{}) ([]byte, error) {
+	body, err := json.Marshal(problem)
+	if err != nil || len(fields) == 0 {
+		return body, err
+	}
+	members := map[string]interface{}{}
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, err
+	}
+	for k, v := range fields {
+		if _, reserved := members[k]; !reserved {
+			members[k] = v
+		}
+	}
+	return json.Marshal(members)
+}
+
+//RequestID returns the ID of the request from its X-Request-ID header.
+//If the request has none, a random ID is generated and set on the response.
+func RequestID(w http.ResponseWriter, r *http.Request) string {
+	if id := r.Header.Get(HeaderRequestID); id != "" {
+		return id
+	}
+	if id := w.Header().Get(HeaderRequestID); id != "" {
+		return id
+	}
+	id := NewRequestID()
+	w.Header().Set(HeaderRequestID, id)
+	return id
+}
+
+//NewRequestID returns a random request ID
+func NewRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"database/sql"
+	"time"
+)
+
+//MySQLStore represents an audit.Store backed by MySQL.
+type MySQLStore struct {
+	Client *sql.DB
+}
+
+//NewMySQLStore constructs a new MySQLStore
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	if db != nil {
+		return &MySQLStore{
+			Client: db,
+		}
+	}
+	return nil
+}
+
+//Insert records the entry, setting its ID and CreatedAt
+func (ms *MySQLStore) Insert(entry *Entry) error {
+	entry.CreatedAt = time.Now()
+	insq := "insert into audit_log(actor_id, action, target_id, detail, created_at) values (?, ?, ?, ?, ?)"
+	res, err := ms.Client.Exec(insq, entry.ActorID, entry.Action, entry.TargetID, entry.Detail, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	entry.ID, err = res.LastInsertId()
+	return err
+}
+
+//List returns one page of entries, most recent first
+func (ms *MySQLStore) List(offset int, limit int) ([]*Entry, error) {
+	rows, err := ms.Client.Query("select audit_id, actor_id, action, target_id, detail, created_at from audit_log "+
+		"order by audit_id desc limit ? offset ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []*Entry{}
+	for rows.Next() {
+		entry := &Entry{}
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetID,
+			&entry.Detail, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"time"
+)
+
+//ActionSearchUsers records an admin listing or searching users
+const ActionSearchUsers = "user.search"
+
+//ActionSuspendUser records an admin suspending a user
+const ActionSuspendUser = "user.suspend"
+
+//ActionUnsuspendUser records an admin lifting a user's suspension
+const ActionUnsuspendUser = "user.unsuspend"
+
+//ActionResetPassword records an admin forcing a user to reset their password
+const ActionResetPassword = "user.password_reset"
+
+//ActionImpersonateUser records an admin starting a session as another user
+const ActionImpersonateUser = "user.impersonate"
+
+//Entry is one action recorded in the audit trail
+type Entry struct {
+	ID        int64     `json:"id"`
+	ActorID   int64     `json:"actorID"`
+	Action    string    `json:"action"`
+	TargetID  int64     `json:"targetID"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//Store represents a store for the audit trail
+type Store interface {
+	//Insert records the entry, setting its ID and CreatedAt
+	Insert(entry *Entry) error
+
+	//List returns one page of entries, most recent first
+	List(offset int, limit int) ([]*Entry, error)
+}
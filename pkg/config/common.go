@@ -0,0 +1,112 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//Server configures the timeouts of a service's HTTP server
+type Server struct {
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" env:"READHEADERTIMEOUT" default:"10s" usage:"time allowed to read request headers"`
+	ReadTimeout       time.Duration `yaml:"readTimeout" env:"READTIMEOUT" default:"30s" usage:"time allowed to read a whole request"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout" env:"WRITETIMEOUT" default:"60s" usage:"time allowed to write a response"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout" env:"IDLETIMEOUT" default:"120s" usage:"time an idle keep-alive connection is kept open"`
+}
+
+//NewServer returns an HTTP server for `handler` with the configured timeouts
+func (s *Server) NewServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+	}
+}
+
+//Observability configures logging, tracing and metrics
+type Observability struct {
+	LogLevel      string `yaml:"logLevel" env:"LOGLEVEL" default:"info" usage:"lowest level logged: debug, info, warn or error"`
+	TraceExporter string `yaml:"traceExporter" env:"TRACEEXPORTER" default:"none" usage:"where spans are sent: otlp, stdout or none"`
+	MetricsAddr   string `yaml:"metricsAddr" env:"METRICSADDR" default:":9090" usage:"address Prometheus metrics are served on"`
+}
+
+func (o *Observability) validate(p *problems) {
+	var level slog.Level
+	p.check(level.UnmarshalText([]byte(o.LogLevel)) == nil, "logLevel must be debug, info, warn or error, got %q", o.LogLevel)
+	p.check(oneOf(o.TraceExporter, "otlp", "stdout", "none"), "traceExporter must be otlp, stdout or none, got %q", o.TraceExporter)
+}
+
+//MySQL configures the connection to the MySQL database
+type MySQL struct {
+	DSN string `yaml:"dsn" env:"DSN" required:"true" secret:"true" usage:"MySQL data source name"`
+}
+
+//Redis configures the connection to Redis
+type Redis struct {
+	Addr         string        `yaml:"addr" env:"REDISADDR" required:"true" usage:"Redis address"`
+	Password     string        `yaml:"password" env:"REDISPASSWORD" secret:"true" usage:"Redis password"`
+	DB           int           `yaml:"db" env:"REDISDB" usage:"Redis database number"`
+	PoolSize     int           `yaml:"poolSize" env:"REDISPOOLSIZE" usage:"maximum connections, 0 for 10 per CPU"`
+	DialTimeout  time.Duration `yaml:"dialTimeout" env:"REDISDIALTIMEOUT" default:"5s" usage:"time allowed to connect to Redis"`
+	ReadTimeout  time.Duration `yaml:"readTimeout" env:"REDISREADTIMEOUT" default:"3s" usage:"time allowed to read a Redis reply"`
+	WriteTimeout time.Duration `yaml:"writeTimeout" env:"REDISWRITETIMEOUT" default:"3s" usage:"time allowed to send a Redis command"`
+}
+
+//Options returns the options of a Redis client
+func (r *Redis) Options() *redis.Options {
+	return &redis.Options{
+		Addr:         r.Addr,
+		Password:     r.Password,
+		DB:           r.DB,
+		PoolSize:     r.PoolSize,
+		DialTimeout:  r.DialTimeout,
+		ReadTimeout:  r.ReadTimeout,
+		WriteTimeout: r.WriteTimeout,
+	}
+}
+
+func (r *Redis) validate(p *problems) {
+	p.check(r.DB >= 0, "redis.db can't be negative")
+	p.check(r.PoolSize >= 0, "redis.poolSize can't be negative")
+	p.check(r.DialTimeout > 0 && r.ReadTimeout > 0 && r.WriteTimeout > 0, "redis timeouts must be positive")
+}
+
+//Internal configures mutual TLS between the gateway and the news service.
+//It's disabled unless CA is set.
+type Internal struct {
+	CA   string `yaml:"ca" env:"INTERNALCA" usage:"CA certificate file signing the internal certificates"`
+	Cert string `yaml:"cert" env:"INTERNALCERT" usage:"internal certificate file"`
+	Key  string `yaml:"key" env:"INTERNALKEY" usage:"internal key file"`
+}
+
+//Enabled returns true if mutual TLS is configured
+func (i *Internal) Enabled() bool {
+	return i.CA != ""
+}
+
+func (i *Internal) validate(p *problems) {
+	if i.Enabled() {
+		p.check(i.Cert != "" && i.Key != "", "internal.cert and internal.key are required when internal.ca is set")
+	}
+}
+
+//decodeKey decodes a base64 encoded key of `size` bytes, or returns nil
+func decodeKey(encoded string, size int) []byte {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != size {
+		return nil
+	}
+	return key
+}
+
+//checkEd25519Seed adds a problem if `encoded` isn't a base64 encoded Ed25519 seed
+func checkEd25519Seed(p *problems, name string, encoded string) {
+	p.check(decodeKey(encoded, ed25519.SeedSize) != nil, "%s must be a base64 encoded %d byte Ed25519 seed", name, ed25519.SeedSize)
+}
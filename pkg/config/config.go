@@ -0,0 +1,250 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//Validator is implemented by configurations, to check
+//settings that depend on each other once they're loaded
+type Validator interface {
+	Validate() []error
+}
+
+//Errors lists every problem found loading a configuration
+type Errors []error
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "  " + err.Error()
+	}
+	return "invalid configuration:\n" + strings.Join(lines, "\n")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+//setting is a field of a configuration struct. Its yaml tag is its key in
+//the config file, where nested structs are nested mappings unless tagged
+//",inline". Its env tag names the environment variable setting it, default
+//is its value when it isn't set and usage describes it in -help. It must be
+//set if tagged required:"true", and its value is redacted by Print if
+//tagged secret:"true".
+type setting struct {
+	//path is the dotted path of its key in the config file, and its flag
+	path     string
+	env      string
+	def      string
+	usage    string
+	required bool
+	secret   bool
+	value    reflect.Value
+}
+
+//settings returns the settings of the struct `v`, in order
+func settings(v reflect.Value, prefix string) []*setting {
+	list := []*setting{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		if tag == ",inline" {
+			list = append(list, settings(v.Field(i), prefix)...)
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			list = append(list, settings(v.Field(i), prefix+name+".")...)
+			continue
+		}
+		list = append(list, &setting{
+			path:     prefix + name,
+			env:      field.Tag.Get("env"),
+			def:      field.Tag.Get("default"),
+			usage:    field.Tag.Get("usage"),
+			required: field.Tag.Get("required") == "true",
+			secret:   field.Tag.Get("secret") == "true",
+			value:    v.Field(i),
+		})
+	}
+	return list
+}
+
+//set parses `s` into the setting's value
+func (s *setting) set(value string) error {
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q must be a duration, such as 30s or 1h", value)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(value)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q must be an integer", value)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q must be true or false", value)
+		}
+		s.value.SetBool(b)
+	default:
+		panic("config: unsupported type " + s.value.Type().String() + " for " + s.path)
+	}
+	return nil
+}
+
+//Load fills `cfg`, a pointer to a struct of settings, from, in increasing
+//precedence, the defaults in its tags, the YAML file named by -config or
+//CONFIGFILE, environment variables and command line flags. Each setting's
+//flag is the dotted path of its key in the file, such as -redis.addr.
+//Every invalid or missing setting is reported in the returned Errors, along
+//with the problems found by cfg.Validate. Load returns true if the
+//-print-config flag was given.
+func Load(name string, cfg Validator, args []string) (bool, error) {
+	list := settings(reflect.ValueOf(cfg).Elem(), "")
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIGFILE"), "YAML file to load settings from (env CONFIGFILE)")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with secrets redacted, and exit")
+	flags := map[*setting]string{}
+	for _, s := range list {
+		s := s
+		usage := s.usage
+		if s.env != "" {
+			usage += " (env " + s.env + ")"
+		}
+		if s.def != "" {
+			usage += " (default " + s.def + ")"
+		}
+		capture := func(value string) error {
+			flags[s] = value
+			return nil
+		}
+		if s.value.Kind() == reflect.Bool {
+			fs.BoolFunc(s.path, usage, capture)
+		} else {
+			fs.Func(s.path, usage, capture)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+
+	errs := Errors{}
+	for _, s := range list {
+		if s.def != "" {
+			if err := s.set(s.def); err != nil {
+				panic("config: invalid default for " + s.path + ": " + err.Error())
+			}
+		}
+	}
+	if *file != "" {
+		errs = append(errs, loadFile(*file, cfg)...)
+	}
+	for _, s := range list {
+		if value, set := os.LookupEnv(s.env); s.env != "" && set {
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", s.env, err))
+			}
+		}
+	}
+	for _, s := range list {
+		if value, set := flags[s]; set {
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %v", s.path, err))
+			}
+		}
+	}
+	for _, s := range list {
+		if s.required && s.value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s is required, set %s, -%s or %s in the config file", s.path, s.env, s.path, s.path))
+		}
+	}
+	errs = append(errs, cfg.Validate()...)
+	if len(errs) > 0 {
+		return *printConfig, errs
+	}
+	return *printConfig, nil
+}
+
+//loadFile decodes the YAML file at `path` into cfg. Unknown keys are
+//reported, so a misspelled setting isn't silently ignored.
+func loadFile(path string, cfg interface{}) []error {
+	f, err := os.Open(path)
+	if err != nil {
+		return []error{fmt.Errorf("error reading config file: %v", err)}
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(cfg)
+	if err == nil || err == io.EOF {
+		return nil
+	}
+	typeErr := &yaml.TypeError{}
+	if !errors.As(err, &typeErr) {
+		return []error{fmt.Errorf("%s: %v", path, err)}
+	}
+	errs := []error{}
+	for _, msg := range typeErr.Errors {
+		errs = append(errs, fmt.Errorf("%s: %s", path, msg))
+	}
+	return errs
+}
+
+//MustLoad loads `cfg` as Load does. If the configuration is invalid, the
+//problems are printed and the process exits. With -print-config, the
+//configuration is printed and the process exits.
+func MustLoad(name string, cfg Validator, args []string) {
+	printConfig, err := Load(name, cfg, args)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if printConfig {
+		Print(os.Stdout, cfg)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
+		os.Exit(0)
+	}
+}
+
+//problems collects the problems found by a Validate method
+type problems []error
+
+//check adds the problem if `ok` is false
+func (p *problems) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		*p = append(*p, fmt.Errorf(format, args...))
+	}
+}
+
+//oneOf returns true if `value` is one of the options
+func oneOf(value string, options ...string) bool {
+	for _, option := range options {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
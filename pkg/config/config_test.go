@@ -0,0 +1,178 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testNested struct {
+	Addr     string `yaml:"addr" env:"TESTCFG_NESTED_ADDR" default:"localhost:1"`
+	Password string `yaml:"password" env:"TESTCFG_NESTED_PASSWORD" secret:"true"`
+}
+
+type testCommon struct {
+	Level string `yaml:"level" env:"TESTCFG_LEVEL" default:"info"`
+}
+
+type testConfig struct {
+	testCommon  `yaml:",inline"`
+	FromDefault string        `yaml:"fromDefault" env:"TESTCFG_FROM_DEFAULT" default:"default"`
+	FromFile    string        `yaml:"fromFile" env:"TESTCFG_FROM_FILE" default:"default"`
+	FromEnv     string        `yaml:"fromEnv" env:"TESTCFG_FROM_ENV" default:"default"`
+	FromFlag    string        `yaml:"fromFlag" env:"TESTCFG_FROM_FLAG" default:"default"`
+	Count       int           `yaml:"count" env:"TESTCFG_COUNT" default:"1"`
+	Timeout     time.Duration `yaml:"timeout" env:"TESTCFG_TIMEOUT" default:"5s"`
+	Enabled     bool          `yaml:"enabled" env:"TESTCFG_ENABLED"`
+	Key         string        `yaml:"key" env:"TESTCFG_KEY" required:"true" secret:"true"`
+	Nested      testNested    `yaml:"nested"`
+}
+
+func (c *testConfig) Validate() []error {
+	p := problems{}
+	p.check(c.Count >= 0, "count must not be negative")
+	return p
+}
+
+//writeConfigFile writes `contents` to a config file and returns its path
+func writeConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+fromFile: file
+fromEnv: file
+fromFlag: file
+level: debug
+nested:
+  addr: file:2
+`)
+	t.Setenv("TESTCFG_FROM_ENV", "env")
+	t.Setenv("TESTCFG_FROM_FLAG", "env")
+	t.Setenv("TESTCFG_KEY", "secret")
+	t.Setenv("TESTCFG_TIMEOUT", "1m")
+	cfg := &testConfig{}
+	printConfig, err := Load("test", cfg, []string{"-config", path, "-fromFlag", "flag", "-nested.addr", "flag:3", "-enabled"})
+	if err != nil {
+		t.Fatalf("Load: unexpected error %v", err)
+	}
+	if printConfig {
+		t.Error("Load: got printConfig without -print-config")
+	}
+	got := map[string]string{
+		"fromDefault": cfg.FromDefault,
+		"fromFile":    cfg.FromFile,
+		"fromEnv":     cfg.FromEnv,
+		"fromFlag":    cfg.FromFlag,
+		"level":       cfg.Level,
+		"nested.addr": cfg.Nested.Addr,
+	}
+	want := map[string]string{
+		"fromDefault": "default",
+		"fromFile":    "file",
+		"fromEnv":     "env",
+		"fromFlag":    "flag",
+		"level":       "debug",
+		"nested.addr": "flag:3",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s: got %q, want %q", key, got[key], value)
+		}
+	}
+	if cfg.Count != 1 || cfg.Timeout != time.Minute || !cfg.Enabled || cfg.Key != "secret" {
+		t.Errorf("got count %d, timeout %v, enabled %v, key %q", cfg.Count, cfg.Timeout, cfg.Enabled, cfg.Key)
+	}
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	t.Setenv("CONFIGFILE", writeConfigFile(t, "key: from-file\n"))
+	cfg := &testConfig{}
+	if _, err := Load("test", cfg, nil); err != nil {
+		t.Fatalf("Load: unexpected error %v", err)
+	}
+	if cfg.Key != "from-file" {
+		t.Errorf("key: got %q, want from-file", cfg.Key)
+	}
+}
+
+func TestLoadAggregatesErrors(t *testing.T) {
+	path := writeConfigFile(t, `
+count: many
+unknownSetting: true
+`)
+	t.Setenv("TESTCFG_TIMEOUT", "soon")
+	t.Setenv("TESTCFG_ENABLED", "maybe")
+	_, err := Load("test", &testConfig{}, []string{"-config", path, "-count", "-1"})
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("Load: got %v, want Errors", err)
+	}
+	//every problem is reported at once: the two in the file, the two
+	//environment variables, the missing key and the failed validation
+	wants := []string{"many", "unknownSetting", "TESTCFG_TIMEOUT", "TESTCFG_ENABLED", "key is required", "count must not be negative"}
+	if len(errs) != len(wants) {
+		t.Errorf("Load: got %d errors, want %d:\n%v", len(errs), len(wants), errs)
+	}
+	message := errs.Error()
+	for _, want := range wants {
+		if !strings.Contains(message, want) {
+			t.Errorf("Load: errors don't mention %q:\n%v", want, message)
+		}
+	}
+}
+
+func TestLoadInvalidFlag(t *testing.T) {
+	t.Setenv("TESTCFG_KEY", "secret")
+	_, err := Load("test", &testConfig{}, []string{"-timeout", "soon"})
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 1 || !strings.Contains(errs[0].Error(), "-timeout") {
+		t.Errorf("Load: got %v, want one error about -timeout", err)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	t.Setenv("TESTCFG_KEY", "top-secret-key")
+	t.Setenv("TESTCFG_NESTED_PASSWORD", "top-secret-password")
+	cfg := &testConfig{}
+	printConfig, err := Load("test", cfg, []string{"-print-config"})
+	if err != nil {
+		t.Fatalf("Load: unexpected error %v", err)
+	}
+	if !printConfig {
+		t.Error("Load: got no printConfig with -print-config")
+	}
+	buffer := &bytes.Buffer{}
+	if err := Print(buffer, cfg); err != nil {
+		t.Fatalf("Print: unexpected error %v", err)
+	}
+	printed := buffer.String()
+	if strings.Contains(printed, "top-secret") {
+		t.Errorf("Print: secret values weren't redacted:\n%s", printed)
+	}
+	for _, want := range []string{
+		"key: '[redacted]' # TESTCFG_KEY",
+		"  password: '[redacted]' # TESTCFG_NESTED_PASSWORD",
+		"level: info # TESTCFG_LEVEL",
+		"timeout: 5s # TESTCFG_TIMEOUT",
+		"nested:",
+	} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("Print: output doesn't contain %q:\n%s", want, printed)
+		}
+	}
+
+	//the printed configuration loads as a config file
+	unset := &testConfig{}
+	if errs := loadFile(writeConfigFile(t, printed), unset); len(errs) != 0 {
+		t.Errorf("loading the printed configuration: %v", errs)
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/sessions"
+	"github.com/2charm/spectrum-api/pkg/users"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//Gateway is the configuration of the API gateway
+type Gateway struct {
+	Addr           string        `yaml:"addr" env:"ADDR" required:"true" usage:"address to listen on"`
+	TLSCert        string        `yaml:"tlsCert" env:"TLSCERT" required:"true" usage:"TLS certificate file"`
+	TLSKey         string        `yaml:"tlsKey" env:"TLSKEY" required:"true" usage:"TLS key file"`
+	RateLimitStore string        `yaml:"rateLimitStore" env:"RATELIMITSTORE" default:"redis" usage:"where rate limit buckets are kept: redis or memory"`
+	ExportTTL      time.Duration `yaml:"exportTTL" env:"EXPORTTTL" default:"1h" usage:"how long account data exports are kept"`
+	Observability  `yaml:",inline"`
+	Server         Server    `yaml:"server"`
+	News           News      `yaml:"news"`
+	Redis          Redis     `yaml:"redis"`
+	MySQL          MySQL     `yaml:"mysql"`
+	Session        Session   `yaml:"session"`
+	Passwords      Passwords `yaml:"passwords"`
+	Identity       Signer    `yaml:"identity"`
+	Internal       Internal  `yaml:"internal"`
+}
+
+//News configures the gateway's calls to the news service
+type News struct {
+	Addr            string        `yaml:"addr" env:"NEWSADDR" required:"true" usage:"address of the news service"`
+	Timeout         time.Duration `yaml:"timeout" env:"NEWSTIMEOUT" default:"30s" usage:"time allowed for the gateway's own calls to the news service"`
+	BreakerFailures int           `yaml:"breakerFailures" env:"NEWSBREAKERFAILURES" default:"5" usage:"consecutive failures that open the news service's breaker"`
+	BreakerCooldown time.Duration `yaml:"breakerCooldown" env:"NEWSBREAKERCOOLDOWN" default:"30s" usage:"how long the breaker stays open"`
+}
+
+//Session configures sessions and the credentials that carry them
+type Session struct {
+	Mode                string        `yaml:"mode" env:"SESSIONMODE" default:"session" usage:"session or token"`
+	Transport           string        `yaml:"transport" env:"SESSIONTRANSPORT" default:"header" usage:"how credentials are sent: header or cookie"`
+	Duration            time.Duration `yaml:"duration" env:"SESSIONDURATION" default:"1h" usage:"how long session state is kept after it's last saved"`
+	AccessTokenDuration time.Duration `yaml:"accessTokenDuration" env:"ACCESSTOKENDURATION" default:"15m" usage:"how long access tokens are valid in token mode"`
+	MFADuration         time.Duration `yaml:"mfaDuration" env:"MFADURATION" default:"5m" usage:"how long a sign-in may wait for its two-factor code"`
+	TokenAlg            string        `yaml:"tokenAlg" env:"TOKENALG" default:"HS256" usage:"access token algorithm: HS256 or EdDSA"`
+	TokenEdKey          string        `yaml:"tokenEdKey" env:"TOKENEDKEY" secret:"true" usage:"base64 encoded Ed25519 seed signing access tokens with EdDSA"`
+	Key                 string        `yaml:"key" env:"SESSIONKEY" secret:"true" usage:"single session signing key"`
+	Keys                string        `yaml:"keys" env:"SESSIONKEYS" secret:"true" usage:"session signing key ring, as id:secret pairs"`
+	KeyFile             string        `yaml:"keyFile" env:"SESSIONKEYFILE" usage:"file of session signing keys, watched for rotated keys"`
+	CookieDomain        string        `yaml:"cookieDomain" env:"COOKIEDOMAIN" usage:"domain of session cookies"`
+	CookieSameSite      string        `yaml:"cookieSameSite" env:"COOKIESAMESITE" default:"strict" usage:"SameSite of session cookies: strict or lax"`
+	CORSOrigin          string        `yaml:"corsOrigin" env:"CORSORIGIN" usage:"origin allowed to send cookies, required with cookie transport"`
+}
+
+//EdDSAKey returns the private key signing access tokens with EdDSA
+func (s *Session) EdDSAKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(decodeKey(s.TokenEdKey, ed25519.SeedSize))
+}
+
+func (s *Session) validate(p *problems) {
+	p.check(oneOf(s.Mode, "session", "token"), "session.mode must be session or token, got %q", s.Mode)
+	p.check(oneOf(s.Transport, "header", "cookie"), "session.transport must be header or cookie, got %q", s.Transport)
+	p.check(s.Duration > 0 && s.AccessTokenDuration > 0 && s.MFADuration > 0, "session durations must be positive")
+	p.check(oneOf(s.TokenAlg, sessions.AlgHS256, sessions.AlgEdDSA), "session.tokenAlg must be %s or %s, got %q",
+		sessions.AlgHS256, sessions.AlgEdDSA, s.TokenAlg)
+	if s.TokenAlg == sessions.AlgEdDSA {
+		checkEd25519Seed(p, "session.tokenEdKey", s.TokenEdKey)
+	}
+	p.check(s.Key != "" || s.Keys != "" || s.KeyFile != "", "one of session.key, session.keys or session.keyFile is required")
+	p.check(oneOf(s.CookieSameSite, "strict", "lax"), "session.cookieSameSite must be strict or lax, got %q", s.CookieSameSite)
+	if s.Transport == "cookie" {
+		p.check(s.CORSOrigin != "", "session.corsOrigin is required with cookie transport")
+	}
+}
+
+//Passwords configures password hashing and the password policy
+type Passwords struct {
+	Hasher     string `yaml:"hasher" env:"PASSWORDHASHER" default:"argon2id" usage:"hasher for new passwords: argon2id or bcrypt"`
+	BcryptCost int    `yaml:"bcryptCost" env:"BCRYPTCOST" default:"13" usage:"bcrypt cost"`
+	MinLength  int    `yaml:"minLength" env:"PASSWORDMINLENGTH" default:"8" usage:"minimum password length"`
+	MinScore   int    `yaml:"minScore" env:"PASSWORDMINSCORE" default:"2" usage:"minimum password strength score, from 0 to 4"`
+	Breached   string `yaml:"breached" env:"BREACHEDPASSWORDS" usage:"breached password hash file, or directory of SHA-1 prefix range files"`
+}
+
+func (pw *Passwords) validate(p *problems) {
+	_, err := users.PasswordHasher(pw.Hasher)
+	p.check(err == nil, "passwords.hasher must be argon2id or bcrypt, got %q", pw.Hasher)
+	p.check(pw.BcryptCost >= bcrypt.MinCost && pw.BcryptCost <= bcrypt.MaxCost, "passwords.bcryptCost must be between %d and %d",
+		bcrypt.MinCost, bcrypt.MaxCost)
+	p.check(pw.MinLength > 0, "passwords.minLength must be positive")
+	p.check(pw.MinScore >= 0 && pw.MinScore <= 4, "passwords.minScore must be between 0 and 4")
+}
+
+//Signer configures the signing of the user assertions sent to the news
+//service. EdKey selects Ed25519, otherwise Key is a shared HMAC key.
+type Signer struct {
+	Key   string        `yaml:"key" env:"IDENTITYKEY" secret:"true" usage:"HMAC key shared with the news service"`
+	EdKey string        `yaml:"edKey" env:"IDENTITYEDKEY" secret:"true" usage:"base64 encoded Ed25519 seed"`
+	TTL   time.Duration `yaml:"ttl" env:"IDENTITYTTL" default:"30s" usage:"how long each assertion is valid"`
+}
+
+//EdDSAKey returns the Ed25519 private key, or nil if EdKey isn't set
+func (s *Signer) EdDSAKey() ed25519.PrivateKey {
+	if s.EdKey == "" {
+		return nil
+	}
+	return ed25519.NewKeyFromSeed(decodeKey(s.EdKey, ed25519.SeedSize))
+}
+
+func (s *Signer) validate(p *problems) {
+	if s.EdKey != "" {
+		checkEd25519Seed(p, "identity.edKey", s.EdKey)
+	} else {
+		p.check(s.Key != "", "identity.key or identity.edKey is required")
+	}
+	p.check(s.TTL > 0, "identity.ttl must be positive")
+}
+
+func (g *Gateway) Validate() []error {
+	p := problems{}
+	p.check(oneOf(g.RateLimitStore, "redis", "memory"), "rateLimitStore must be redis or memory, got %q", g.RateLimitStore)
+	p.check(g.ExportTTL > 0, "exportTTL must be positive")
+	p.check(g.News.Timeout > 0 && g.News.BreakerCooldown > 0, "news timeouts must be positive")
+	p.check(g.News.BreakerFailures > 0, "news.breakerFailures must be positive")
+	g.Observability.validate(&p)
+	g.Redis.validate(&p)
+	g.Session.validate(&p)
+	g.Passwords.validate(&p)
+	g.Identity.validate(&p)
+	g.Internal.validate(&p)
+	return p
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"time"
+)
+
+//NewsService is the configuration of the news service
+type NewsService struct {
+	Addr          string `yaml:"addr" env:"ADDR" required:"true" usage:"address to listen on"`
+	Observability `yaml:",inline"`
+	Server        Server   `yaml:"server"`
+	MySQL         MySQL    `yaml:"mysql"`
+	NewsAPI       NewsAPI  `yaml:"newsapi"`
+	Cache         Cache    `yaml:"cache"`
+	Identity      Verifier `yaml:"identity"`
+	Internal      Internal `yaml:"internal"`
+}
+
+//NewsAPI configures the calls to NewsAPI. The default budget is the
+//developer plan's 100 calls a day, with enough reserved to refresh the
+//top articles every three hours.
+type NewsAPI struct {
+	Key      string        `yaml:"key" env:"APIKEY" required:"true" secret:"true" usage:"NewsAPI key"`
+	Budget   int           `yaml:"budget" env:"NEWSAPIBUDGET" default:"100" usage:"NewsAPI calls allowed a day"`
+	Reserved int           `yaml:"reserved" env:"NEWSAPIRESERVED" default:"64" usage:"calls of the budget reserved to refresh the top articles"`
+	Timeout  time.Duration `yaml:"timeout" env:"NEWSAPITIMEOUT" default:"10s" usage:"time allowed for each NewsAPI call"`
+}
+
+//Cache configures how long NewsAPI responses are cached
+type Cache struct {
+	TopArticlesTTL     time.Duration `yaml:"topArticlesTTL" env:"TOPARTICLESTTL" default:"3h" usage:"how long the top articles are fresh"`
+	RelatedArticlesTTL time.Duration `yaml:"relatedArticlesTTL" env:"RELATEDARTICLESTTL" default:"15h" usage:"how long related articles are fresh"`
+	StaleFor           time.Duration `yaml:"staleFor" env:"STALEFOR" default:"24h" usage:"how long stale articles are kept, to serve when NewsAPI is unavailable"`
+}
+
+//Verifier configures the verification of the user assertions signed by
+//the gateway. EdPubKey selects Ed25519, otherwise Key is a shared HMAC key.
+type Verifier struct {
+	Key      string        `yaml:"key" env:"IDENTITYKEY" secret:"true" usage:"HMAC key shared with the gateway"`
+	EdPubKey string        `yaml:"edPubKey" env:"IDENTITYEDPUBKEY" usage:"base64 encoded Ed25519 public key of the gateway"`
+	Skew     time.Duration `yaml:"skew" env:"IDENTITYSKEW" default:"5s" usage:"clock difference tolerated between the services"`
+}
+
+//EdDSAPublicKey returns the Ed25519 public key, or nil if EdPubKey isn't set
+func (v *Verifier) EdDSAPublicKey() ed25519.PublicKey {
+	if v.EdPubKey == "" {
+		return nil
+	}
+	return ed25519.PublicKey(decodeKey(v.EdPubKey, ed25519.PublicKeySize))
+}
+
+func (v *Verifier) validate(p *problems) {
+	if v.EdPubKey != "" {
+		p.check(decodeKey(v.EdPubKey, ed25519.PublicKeySize) != nil, "identity.edPubKey must be a base64 encoded %d byte Ed25519 public key",
+			ed25519.PublicKeySize)
+	} else {
+		p.check(v.Key != "", "identity.key or identity.edPubKey is required")
+	}
+	p.check(v.Skew >= 0, "identity.skew can't be negative")
+}
+
+func (n *NewsService) Validate() []error {
+	p := problems{}
+	p.check(n.NewsAPI.Budget > 0, "newsapi.budget must be positive")
+	p.check(n.NewsAPI.Reserved >= 0 && n.NewsAPI.Reserved <= n.NewsAPI.Budget, "newsapi.reserved must be between 0 and the budget")
+	p.check(n.NewsAPI.Timeout > 0, "newsapi.timeout must be positive")
+	p.check(n.Cache.TopArticlesTTL > 0 && n.Cache.RelatedArticlesTTL > 0, "cache TTLs must be positive")
+	p.check(n.Cache.StaleFor >= 0, "cache.staleFor can't be negative")
+	n.Observability.validate(&p)
+	n.Identity.validate(&p)
+	n.Internal.validate(&p)
+	return p
+}
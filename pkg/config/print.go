@@ -0,0 +1,63 @@
+package config
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//redacted replaces the values of secret settings
+const redacted = "[redacted]"
+
+//Print writes the configuration as YAML, in the format of the config
+//file, with the values of secret settings redacted. Each setting is
+//commented with the environment variable that sets it.
+func Print(w io.Writer, cfg interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(toNode(reflect.ValueOf(cfg).Elem())); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+//toNode returns a YAML mapping of the settings in the struct `v`
+func toNode(v reflect.Value) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		if tag == ",inline" {
+			node.Content = append(node.Content, toNode(v.Field(i)).Content...)
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: name}
+		value := &yaml.Node{}
+		fv := v.Field(i)
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			value = toNode(fv)
+		case field.Tag.Get("secret") == "true" && !fv.IsZero():
+			value.SetString(redacted)
+		case field.Type == durationType:
+			value.SetString(time.Duration(fv.Int()).String())
+		default:
+			if err := value.Encode(fv.Interface()); err != nil {
+				value.SetString(err.Error())
+			}
+		}
+		if env := field.Tag.Get("env"); env != "" {
+			value.LineComment = env
+		}
+		node.Content = append(node.Content, key, value)
+	}
+	return node
+}
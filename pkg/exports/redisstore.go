@@ -0,0 +1,82 @@
+package exports
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//RedisStore represents a Store backed by redis. A job's archive is kept
+//under its own key, so polling a job doesn't read the archive.
+type RedisStore struct {
+	//Redis client used to talk to redis server.
+	Client *redis.Client
+	//TTL is how long jobs are kept after they were last saved
+	TTL time.Duration
+}
+
+//NewRedisStore constructs a new RedisStore
+func NewRedisStore(client *redis.Client, ttl time.Duration) *RedisStore {
+	return &RedisStore{Client: client, TTL: ttl}
+}
+
+//record is a Job as it's stored in redis, including the user ID
+//that is left out of API responses
+type record struct {
+	*Job
+	UserID int64 `json:"userID"`
+}
+
+//Save saves the job and its archive, if it has one, resetting their expiry
+func (rs *RedisStore) Save(job *Job) error {
+	j, err := json.Marshal(&record{Job: job, UserID: job.UserID})
+	if err != nil {
+		return err
+	}
+	pipe := rs.Client.TxPipeline()
+	pipe.Set(jobKey(job.ID), j, rs.TTL)
+	if len(job.Archive) > 0 {
+		pipe.Set(archiveKey(job.ID), job.Archive, rs.TTL)
+	}
+	_, err = pipe.Exec()
+	return err
+}
+
+//Get returns the job with the given ID, with its archive if it's ready
+func (rs *RedisStore) Get(id string) (*Job, error) {
+	j, err := rs.Client.Get(jobKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &record{Job: &Job{}}
+	if err := json.Unmarshal(j, rec); err != nil {
+		return nil, err
+	}
+	job := rec.Job
+	job.UserID = rec.UserID
+	if job.Status != StatusReady {
+		return job, nil
+	}
+	job.Archive, err = rs.Client.Get(archiveKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+//jobKey returns the redis key of the job with the given ID
+func jobKey(id string) string {
+	return "export:" + id
+}
+
+//archiveKey returns the redis key of the archive of the job with the given ID
+func archiveKey(id string) string {
+	return "export:" + id + ":archive"
+}
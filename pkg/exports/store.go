@@ -0,0 +1,40 @@
+package exports
+
+import (
+	"errors"
+	"time"
+)
+
+//StatusPending, StatusReady and StatusFailed are the states of a Job
+const (
+	StatusPending = "pending"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+//ErrJobNotFound is returned when a job can't be found, or has expired
+var ErrJobNotFound = errors.New("export job not found")
+
+//Job tracks an account data export that is generated asynchronously
+type Job struct {
+	ID        string    `json:"id"`
+	UserID    int64     `json:"-"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	Error     string    `json:"error,omitempty"`
+	StatusURL string    `json:"statusURL"`
+	//Archive is the zip archive, once the job is ready
+	Archive []byte `json:"-"`
+}
+
+//Store represents a store for export jobs. Jobs are kept outside the
+//gateway process, so a job started on one gateway instance can be polled
+//and downloaded from any other, and survives the gateway restarting.
+type Store interface {
+	//Save saves the job, replacing any saved with the same ID.
+	//The job expires once the store's TTL has passed.
+	Save(job *Job) error
+
+	//Get returns the job with the given ID, or ErrJobNotFound
+	Get(id string) (*Job, error)
+}
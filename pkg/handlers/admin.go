@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/audit"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+const adminUsersResourcePath = "/v1/admin/users/"
+
+//defaultPageSize and maxPageSize bound the pageSize query parameter
+const defaultPageSize = 20
+const maxPageSize = 100
+
+//userWithEmail is the view of a user that includes the email, shown to
+//admins and in the user's own data export
+type userWithEmail struct {
+	*users.User
+	Email string `json:"email"`
+}
+
+//userPage is one page of users returned by AdminUsersHandler
+type userPage struct {
+	Users    []*userWithEmail `json:"users"`
+	Total    int              `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"pageSize"`
+}
+
+//getPage parses the 1-based page and the pageSize query parameters
+func getPage(r *http.Request) (int, int, error) {
+	page, pageSize := 1, defaultPageSize
+	var err error
+	if val := r.URL.Query().Get("page"); val != "" {
+		if page, err = strconv.Atoi(val); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("page must be a positive integer")
+		}
+	}
+	if val := r.URL.Query().Get("pageSize"); val != "" {
+		if pageSize, err = strconv.Atoi(val); err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return 0, 0, fmt.Errorf("pageSize must be between 1 and %d", maxPageSize)
+		}
+	}
+	return page, pageSize, nil
+}
+
+//recordAudit writes an action taken by the admin in the session to the audit trail
+func (ctx *HandlerContext) recordAudit(r *http.Request, action string, targetID int64, detail string) error {
+	return ctx.AuditStore.Insert(&audit.Entry{
+		ActorID:  getContextState(r).User.ID,
+		Action:   action,
+		TargetID: targetID,
+		Detail:   detail,
+	})
+}
+
+//canManage returns true if the actor may suspend or reset the target.
+//Only admins may act on admins and moderators.
+func canManage(actor *users.User, target *users.User) bool {
+	return actor.HasRole(users.RoleAdmin) ||
+		(!target.HasRole(users.RoleAdmin) && !target.HasRole(users.RoleModerator))
+}
+
+//AdminUsersHandler lists users, optionally filtered by the `q` query
+//parameter, one page at a time. It must be wrapped with RequireRole.
+func (ctx *HandlerContext) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	page, pageSize, err := getPage(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+	query := r.URL.Query().Get("q")
+	if err := ctx.recordAudit(r, audit.ActionSearchUsers, 0, query); err != nil {
+		apierror.Write(w, r, apierror.Internal("error writing audit trail", err))
+		return
+	}
+	found, total, err := ctx.userStore(r).Search(query, (page-1)*pageSize, pageSize)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error searching users", err))
+		return
+	}
+	result := &userPage{
+		Users:    []*userWithEmail{},
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	for _, user := range found {
+		result.Users = append(result.Users, &userWithEmail{User: user, Email: user.Email})
+	}
+	respondJSON(w, r, http.StatusOK, result)
+}
+
+//AdminSpecificUserHandler handles POST /v1/admin/users/{id}/{action}, where
+//action is suspend, unsuspend, password-reset or impersonate. Every action is
+//written to the audit trail before it's taken. It must be wrapped with RequireRole.
+func (ctx *HandlerContext) AdminSpecificUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	segs := strings.Split(strings.TrimPrefix(r.URL.Path, adminUsersResourcePath), "/")
+	if len(segs) != 2 {
+		apierror.Write(w, r, apierror.NotFound("resource not found"))
+		return
+	}
+	id, err := strconv.ParseInt(segs[0], 10, 64)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("user ID must be an integer"))
+		return
+	}
+	target, err := ctx.userStore(r).GetByID(id)
+	if err != nil {
+		apierror.Write(w, r, apierror.NotFound("user not found"))
+		return
+	}
+	actor := getContextState(r).User
+	if (segs[1] == "suspend" || segs[1] == "unsuspend" || segs[1] == "password-reset") && !canManage(actor, target) {
+		apierror.Write(w, r, apierror.Forbidden("only admins can manage admins and moderators"))
+		return
+	}
+
+	switch segs[1] {
+	case "suspend", "unsuspend":
+		suspend := segs[1] == "suspend"
+		if suspend && target.HasRole(users.RoleAdmin) {
+			apierror.Write(w, r, apierror.Forbidden("admins can't be suspended"))
+			return
+		}
+		action := audit.ActionUnsuspendUser
+		if suspend {
+			action = audit.ActionSuspendUser
+		}
+		if err := ctx.recordAudit(r, action, target.ID, ""); err != nil {
+			apierror.Write(w, r, apierror.Internal("error writing audit trail", err))
+			return
+		}
+		if err := ctx.userStore(r).SetSuspended(target.ID, suspend); err != nil {
+			apierror.Write(w, r, apierror.Internal("error updating user", err))
+			return
+		}
+		if suspend {
+			//ending the sessions and revoking the user makes their sessions
+			//and access tokens stop validating immediately
+			if err := ctx.endUserSessions(r, target.ID); err != nil {
+				apierror.Write(w, r, apierror.Internal("error ending sessions", err))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "password-reset":
+		if err := ctx.recordAudit(r, audit.ActionResetPassword, target.ID, ""); err != nil {
+			apierror.Write(w, r, apierror.Internal("error writing audit trail", err))
+			return
+		}
+		if err := ctx.userStore(r).SetPasswordResetRequired(target.ID, true); err != nil {
+			apierror.Write(w, r, apierror.Internal("error updating user", err))
+			return
+		}
+		if err := ctx.endUserSessions(r, target.ID); err != nil {
+			apierror.Write(w, r, apierror.Internal("error ending sessions", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "impersonate":
+		if !actor.HasRole(users.RoleAdmin) || target.HasRole(users.RoleAdmin) || target.Suspended {
+			apierror.Write(w, r, apierror.Forbidden("user can't be impersonated"))
+			return
+		}
+		if err := ctx.recordAudit(r, audit.ActionImpersonateUser, target.ID, ""); err != nil {
+			apierror.Write(w, r, apierror.Internal("error writing audit trail", err))
+			return
+		}
+		sessState := &SessionState{
+			User:           target,
+			ImpersonatorID: actor.ID,
+		}
+		if _, err := ctx.beginSession(w, r, sessState); err != nil {
+			apierror.Write(w, r, apierror.Internal("error starting new session", err))
+			return
+		}
+		respondJSON(w, r, http.StatusCreated, &userWithEmail{User: target, Email: target.Email})
+	default:
+		apierror.Write(w, r, apierror.NotFound("resource not found"))
+	}
+}
+
+//AdminAuditHandler lists the audit trail one page at a time, most recent
+//first. It must be wrapped with RequireRole.
+func (ctx *HandlerContext) AdminAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	page, pageSize, err := getPage(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+	entries, err := ctx.AuditStore.List((page-1)*pageSize, pageSize)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving audit trail", err))
+		return
+	}
+	respondJSON(w, r, http.StatusOK, entries)
+}
@@ -3,143 +3,375 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/2charm/spectrum-api/pkg/apierror"
 	"github.com/2charm/spectrum-api/pkg/sessions"
 	"github.com/2charm/spectrum-api/pkg/users"
+	"github.com/2charm/spectrum-api/pkg/util"
 )
 
 //SessionState represents a session that is started by an authenticated user
 type SessionState struct {
 	StartTime time.Time   `json:"startTime,omitempty"`
 	User      *users.User `json:"user,omitempty"`
+	//MFAPending is set when the password was verified but the
+	//user still has to provide a two-factor code
+	MFAPending bool      `json:"mfaPending,omitempty"`
+	MFAExpires time.Time `json:"mfaExpires,omitempty"`
+	//MFAFailures counts the invalid codes sent for a pending sign-in
+	MFAFailures int `json:"mfaFailures,omitempty"`
+	//PasswordResetPending is set when the user must choose a new
+	//password before the session can be used
+	PasswordResetPending bool `json:"passwordResetPending,omitempty"`
+	//ImpersonatorID is the ID of the admin acting as the user, if any
+	ImpersonatorID int64 `json:"impersonatorID,omitempty"`
+	//UserAgent and ClientIP describe the device that started the session
+	UserAgent string `json:"userAgent,omitempty"`
+	ClientIP  string `json:"clientIP,omitempty"`
+	//VerifiedAt is when User was last loaded from the UserStore
+	VerifiedAt time.Time `json:"verifiedAt,omitempty"`
+}
+
+//Authenticated returns true if the session belongs to a user
+//that has completed every sign-in step
+func (ss *SessionState) Authenticated() bool {
+	return ss.User != nil && !ss.MFAPending && !ss.PasswordResetPending
 }
 
 func (ctx *HandlerContext) UsersHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		contentType := r.Header.Get("Content-Type")
 		if !strings.HasPrefix(contentType, "application/json") {
-			http.Error(w, "The request body must be of type JSON.", http.StatusUnsupportedMediaType)
+			apierror.Write(w, r, apierror.UnsupportedMediaType())
 			return
 		}
 
 		newUser := &users.NewUser{}
 		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
 		if err := dec.Decode(newUser); err != nil {
-			http.Error(w, "Error decoding json into User.", http.StatusBadRequest)
+			apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("Error decoding json into User: %v", err)))
 			return
 		}
 
 		user, err := newUser.ToUser()
-		if err != nil {
-			http.Error(w, "Error creating User from NewUser.", http.StatusBadRequest)
+		if _, invalid := err.(*users.ValidationError); invalid {
+			apierror.Write(w, r, validationError(err))
+			return
+		} else if err != nil {
+			apierror.Write(w, r, apierror.Internal("Error creating User from NewUser.", err))
 			return
 		}
 
-		user, err = ctx.UserStore.Insert(user)
-		if err != nil {
-			http.Error(w, "Error inserting user into User store.", http.StatusInternalServerError)
+		user, err = ctx.userStore(r).Insert(user)
+		if dupErr, duplicate := err.(*users.DuplicateError); duplicate {
+			apierror.Write(w, r, apierror.Conflict(dupErr.Error()).With("field", dupErr.Field))
+			return
+		} else if err != nil {
+			apierror.Write(w, r, apierror.Internal("Error inserting user into User store.", err))
 			return
 		}
 
 		session := &SessionState{
-			StartTime: time.Now(),
-			User:      user,
+			User: user,
 		}
-		_, err = sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, session, w)
+		_, err = ctx.beginSession(w, r, session)
 		if err != nil {
-			http.Error(w, "Error creating session in server.", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal("Error creating session in server.", err))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		if user.ID == 0 {
-			http.Error(w, "Error with adding user to database", http.StatusInternalServerError)
-			return
-		}
-		buffer, err := json.Marshal(user)
-		if err != nil {
-			log.Printf("%v", err)
-			http.Error(w, "Error marshaling JSON.", http.StatusInternalServerError)
-			return
-		}
-		w.Write(buffer)
+		respondJSON(w, r, http.StatusCreated, user)
 	} else {
-		http.Error(w, "Incompatible http method.", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed())
 		return
 	}
 }
 
+//errInvalidCredentials is returned for both unknown emails and wrong
+//passwords, so sign-in doesn't reveal which accounts exist
+var errInvalidCredentials = apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "invalid email or password")
+
 //SessionsHandler handles requests for sessions
 func (ctx *HandlerContext) SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		ctx.listSessions(w, r)
+		return
+	}
 	if r.Method != "POST" {
-		http.Error(w, "incompatible http method", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed())
 		return
 	}
 
 	contentType := r.Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, "application/json") {
-		http.Error(w, "request body must be of type JSON", http.StatusUnsupportedMediaType)
+		apierror.Write(w, r, apierror.UnsupportedMediaType())
 		return
 	}
 
 	creds := &users.Credentials{}
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(creds); err != nil {
-		http.Error(w, fmt.Sprintf("error decoding JSON: %v", err), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("error decoding JSON"))
 		return
 	}
-	user, err := ctx.UserStore.GetByEmail(creds.Email)
+	user, err := ctx.userStore(r).GetByEmail(creds.Email)
 	if err != nil {
-		time.Sleep(time.Second * 7)
-		http.Error(w, "invalid credentials, email not found", http.StatusUnauthorized)
+		//spend as long as a real password check so the response
+		//doesn't reveal whether the email exists
+		users.VerifyDummyHash(creds.Password)
+		apierror.Write(w, r, errInvalidCredentials)
 		return
 	}
 
 	err = user.Authenticate(creds.Password)
+	if signInErr := ctx.userStore(r).InsertSignIn(user.ID, util.ClientIP(r), err == nil); signInErr != nil {
+		slog.ErrorContext(r.Context(), "error recording sign-in", "error", signInErr)
+	}
 	if err != nil {
-		http.Error(w, "invalid credentials, password is not correct", http.StatusUnauthorized)
+		apierror.Write(w, r, errInvalidCredentials)
 		return
 	}
-	sessState := SessionState{
-		StartTime: time.Now(),
-		User:      user,
+	if user.Suspended {
+		apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeAccountSuspended, "account suspended"))
+		return
 	}
-	_, err = sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, sessState, w)
-	if err != nil {
-		http.Error(w, "error starting new session", http.StatusInternalServerError)
+	if user.NeedsRehash() {
+		//upgrade the hash to the configured hasher while we have the password
+		if err := user.SetPassword(creds.Password); err != nil {
+			slog.ErrorContext(r.Context(), "error rehashing password", "error", err)
+		} else if err := ctx.userStore(r).UpdatePassword(user.ID, user.PassHash); err != nil {
+			slog.ErrorContext(r.Context(), "error saving rehashed password", "error", err)
+		}
 	}
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "application/json")
-	buffer, err := json.Marshal(user)
+	if user.TOTPEnabled {
+		sessState := &SessionState{
+			User:       user,
+			MFAPending: true,
+			MFAExpires: time.Now().Add(ctx.MFADuration),
+		}
+		_, err = ctx.beginSession(w, r, sessState)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal("error starting new session", err))
+			return
+		}
+		respondJSON(w, r, http.StatusAccepted, map[string]bool{"mfaRequired": true})
+		return
+	}
+	sessState := &SessionState{
+		User:                 user,
+		PasswordResetPending: user.PasswordResetRequired,
+	}
+	_, err = ctx.beginSession(w, r, sessState)
 	if err != nil {
-		http.Error(w, "error marshaling JSON.", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("error starting new session", err))
 		return
 	}
-	w.Write(buffer)
+	respondJSON(w, r, http.StatusCreated, user)
 }
 
 const sessionResourcePath = "/v1/sessions/"
 
+//SpecificSessionHandler ends sessions. The resource may be "mine" for the
+//current session, "all" for every session of the current user, or the
+//handle of one of the current user's sessions as returned by GET /v1/sessions
 func (ctx *HandlerContext) SpecificSessionHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
-		http.Error(w, "incompatible http method", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed())
 		return
 	}
-	seg := strings.TrimPrefix(r.RequestURI, sessionResourcePath)
-	if seg != "mine" {
-		http.Error(w, "user session invalid", http.StatusForbidden)
+	seg := strings.TrimPrefix(r.URL.Path, sessionResourcePath)
+	if seg == "mine" {
+		sessState := &SessionState{}
+		sid, err := sessions.GetState(r, ctx.SessionConfig, ctx.SessionStore, sessState)
+		if err != nil {
+			apierror.Write(w, r, apierror.Forbidden("user session invalid"))
+			return
+		}
+		if err := ctx.endSession(r, sessState.User.ID, sid); err != nil {
+			apierror.Write(w, r, apierror.Internal("error ending session", err))
+			return
+		}
+		sessions.ClearCredentials(w, ctx.SessionConfig)
+		w.Write([]byte("signed out"))
 		return
 	}
 
-	_, err := sessions.EndSession(r, ctx.SigningKey, ctx.SessionStore)
+	sessState, current, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	sids, err := ctx.sessionStore(r).GetUserSessions(sessState.User.ID)
 	if err != nil {
-		http.Error(w, "error ending session", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("error retrieving sessions", err))
+		return
+	}
+	found := false
+	for _, sid := range sids {
+		if seg != "all" && sid.Handle() != seg {
+			continue
+		}
+		found = true
+		if err := ctx.endSession(r, sessState.User.ID, sid); err != nil {
+			apierror.Write(w, r, apierror.Internal("error ending session", err))
+			return
+		}
+		if sid == current {
+			sessions.ClearCredentials(w, ctx.SessionConfig)
+		}
+	}
+	if !found && seg != "all" {
+		apierror.Write(w, r, apierror.NotFound("session not found"))
 		return
 	}
 	w.Write([]byte("signed out"))
 }
+
+//RefreshHandler rotates the refresh token of a token mode session,
+//issuing a new access token and refresh token
+func (ctx *HandlerContext) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	if ctx.SessionConfig.Mode != sessions.ModeToken {
+		apierror.Write(w, r, apierror.NotFound("refresh tokens are not enabled"))
+		return
+	}
+	sessState := &SessionState{}
+	//the new access token carries the user as they are now, and
+	//suspended users can't refresh
+	reload := func() error {
+		if sessState.User == nil {
+			return sessions.ErrStateNotFound
+		}
+		return ctx.reloadUser(r, sessState)
+	}
+	oldKey, newKey, err := sessions.Refresh(r, ctx.SessionConfig, ctx.SessionStore, sessState, w, reload)
+	if err == errAccountSuspended {
+		apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeAccountSuspended, "account suspended"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("invalid refresh token"))
+		return
+	}
+	if err := ctx.sessionStore(r).RemoveFromUser(sessState.User.ID, oldKey); err != nil {
+		apierror.Write(w, r, apierror.Internal("error updating session index", err))
+		return
+	}
+	if err := ctx.sessionStore(r).AddToUser(sessState.User.ID, newKey); err != nil {
+		apierror.Write(w, r, apierror.Internal("error updating session index", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//sessionInfo describes one of a user's active sessions
+type sessionInfo struct {
+	ID        string    `json:"id"`
+	StartTime time.Time `json:"startTime"`
+	UserAgent string    `json:"userAgent"`
+	ClientIP  string    `json:"clientIP"`
+	Current   bool      `json:"current"`
+}
+
+//listSessions responds with the current user's active sessions
+func (ctx *HandlerContext) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessState, current, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	sids, err := ctx.sessionStore(r).GetUserSessions(sessState.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving sessions", err))
+		return
+	}
+	infos := []*sessionInfo{}
+	for _, sid := range sids {
+		state := &SessionState{}
+		if err := ctx.sessionStore(r).Get(sid, state); err != nil || !state.Authenticated() {
+			continue
+		}
+		infos = append(infos, &sessionInfo{
+			ID:        sid.Handle(),
+			StartTime: state.StartTime,
+			UserAgent: state.UserAgent,
+			ClientIP:  state.ClientIP,
+			Current:   sid == current,
+		})
+	}
+	respondJSON(w, r, http.StatusOK, infos)
+}
+
+//beginSession records the client's device in the session state,
+//begins the session and adds it to the user's session index
+func (ctx *HandlerContext) beginSession(w http.ResponseWriter, r *http.Request, sessState *SessionState) (sessions.SessionID, error) {
+	sessState.StartTime = time.Now()
+	sessState.VerifiedAt = sessState.StartTime
+	sessState.UserAgent = r.UserAgent()
+	sessState.ClientIP = util.ClientIP(r)
+	sid, err := sessions.BeginSession(ctx.SessionConfig, ctx.sessionStore(r), sessState, w)
+	if err != nil {
+		return sessions.InvalidSessionID, err
+	}
+	if err := ctx.sessionStore(r).AddToUser(sessState.User.ID, sid); err != nil {
+		return sessions.InvalidSessionID, err
+	}
+	return sid, nil
+}
+
+//endUserSessions ends every session of the given user ID, and revokes
+//the user so access tokens already issued to them reload it
+func (ctx *HandlerContext) endUserSessions(r *http.Request, userID int64) error {
+	if err := ctx.Revocations.Revoke(userID); err != nil {
+		return err
+	}
+	sids, err := ctx.sessionStore(r).GetUserSessions(userID)
+	if err != nil {
+		return err
+	}
+	for _, sid := range sids {
+		if err := ctx.endSession(r, userID, sid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//endSession deletes the session state and removes the
+//session from its user's session index
+func (ctx *HandlerContext) endSession(r *http.Request, userID int64, sid sessions.SessionID) error {
+	if err := ctx.sessionStore(r).Delete(sid); err != nil {
+		return err
+	}
+	return ctx.sessionStore(r).RemoveFromUser(userID, sid)
+}
+
+//respondJSON writes `value` as a JSON response with the given status code
+func respondJSON(w http.ResponseWriter, r *http.Request, status int, value interface{}) {
+	buffer, err := json.Marshal(value)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error marshaling JSON", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buffer)
+}
+
+//validationError converts a validation error into a 400 error,
+//listing every failing field when the error is a *users.ValidationError
+func validationError(err error) *apierror.Error {
+	if ve, ok := err.(*users.ValidationError); ok {
+		return apierror.New(http.StatusBadRequest, apierror.CodeValidation,
+			"one or more fields are invalid").With("fields", ve.Fields)
+	}
+	return apierror.New(http.StatusBadRequest, apierror.CodeValidation, err.Error())
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/sessions"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+//errAccountSuspended is returned for the sessions of suspended users
+var errAccountSuspended = errors.New("account suspended")
+
+//UserReloadInterval is how long a session keeps the user it loaded before
+//reloading it from the UserStore, so changes made outside the gateway,
+//such as roles granted with cmd/admin, reach sessions. Access tokens
+//keep theirs until they're refreshed.
+const UserReloadInterval = time.Minute * 5
+
+//resolvedState is the authenticated session state of a request,
+//resolved at most once however many handlers ask for it
+type resolvedState struct {
+	once      sync.Once
+	sessState *SessionState
+	sid       sessions.SessionID
+	err       error
+}
+
+//ResolveSession wraps `handler` so the session state of each request is
+//resolved once and shared by the middleware and handlers it passes through
+func (ctx *HandlerContext) ResolveSession(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), resolvedStateKey, &resolvedState{}))
+		handler.ServeHTTP(w, r)
+	})
+}
+
+//getAuthenticatedState returns the session state of a fully signed-in user,
+//resolving it once per request when the request passed through ResolveSession
+func (ctx *HandlerContext) getAuthenticatedState(r *http.Request) (*SessionState, sessions.SessionID, error) {
+	resolved, ok := r.Context().Value(resolvedStateKey).(*resolvedState)
+	if !ok {
+		return ctx.resolveAuthenticatedState(r)
+	}
+	resolved.once.Do(func() {
+		resolved.sessState, resolved.sid, resolved.err = ctx.resolveAuthenticatedState(r)
+	})
+	return resolved.sessState, resolved.sid, resolved.err
+}
+
+//resolveAuthenticatedState gets the session state of a fully signed-in user.
+//The user is reloaded from the UserStore when it was revoked after the
+//state loaded it, so suspensions apply to sessions and access tokens
+//issued before them, and sessions reload it every UserReloadInterval.
+func (ctx *HandlerContext) resolveAuthenticatedState(r *http.Request) (*SessionState, sessions.SessionID, error) {
+	sessState := &SessionState{}
+	sid, err := sessions.GetState(r, ctx.SessionConfig, ctx.SessionStore, sessState)
+	if err != nil {
+		return nil, sessions.InvalidSessionID, err
+	}
+	if !sessState.Authenticated() {
+		return nil, sessions.InvalidSessionID, sessions.ErrStateNotFound
+	}
+	if !ctx.userStale(r, sessState) {
+		return sessState, sid, nil
+	}
+	if err := ctx.reloadUser(r, sessState); err != nil {
+		return nil, sessions.InvalidSessionID, err
+	}
+	//access tokens can't be updated, but sessions keep the reloaded user
+	if ctx.SessionConfig.Mode != sessions.ModeToken {
+		if err := ctx.sessionStore(r).Save(sid, sessState); err != nil {
+			slog.WarnContext(r.Context(), "error saving reloaded user", "error", err)
+		}
+	}
+	return sessState, sid, nil
+}
+
+//userStale returns true if the user in the session state must be reloaded
+func (ctx *HandlerContext) userStale(r *http.Request, sessState *SessionState) bool {
+	if ctx.SessionConfig.Mode != sessions.ModeToken && time.Since(sessState.VerifiedAt) > UserReloadInterval {
+		return true
+	}
+	revokedAt, err := ctx.Revocations.RevokedAt(sessState.User.ID)
+	if err != nil {
+		//reload rather than trust a user that may have been suspended
+		slog.WarnContext(r.Context(), "error checking revocations", "error", err)
+		return true
+	}
+	return !revokedAt.IsZero() && !sessState.VerifiedAt.After(revokedAt)
+}
+
+//AuthenticatedUser returns the current user of a fully signed-in session.
+//Suspended users get errAccountSuspended.
+func (ctx *HandlerContext) AuthenticatedUser(r *http.Request) (*users.User, error) {
+	sessState, _, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		return nil, err
+	}
+	return sessState.User, nil
+}
+
+//reloadUser replaces the user in the session state with the user as it
+//is in the UserStore, and returns errAccountSuspended if they're suspended
+func (ctx *HandlerContext) reloadUser(r *http.Request, sessState *SessionState) error {
+	user, err := ctx.userStore(r).GetByID(sessState.User.ID)
+	if err != nil {
+		return sessions.ErrStateNotFound
+	}
+	if user.Suspended {
+		return errAccountSuspended
+	}
+	sessState.User = user
+	sessState.VerifiedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/ratelimit"
+	"github.com/2charm/spectrum-api/pkg/sessions"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+//countingSessionStore is a MemStore counting the session states it gets
+type countingSessionStore struct {
+	*sessions.MemStore
+	gets int
+}
+
+func (cs *countingSessionStore) Get(sid sessions.SessionID, state interface{}) error {
+	cs.gets++
+	return cs.MemStore.Get(sid, state)
+}
+
+//countingUserStore is a users.Store holding a single user,
+//counting the times it's loaded
+type countingUserStore struct {
+	users.Store
+	user  users.User
+	loads int
+}
+
+func (cs *countingUserStore) GetByID(id int64) (*users.User, error) {
+	cs.loads++
+	if id != cs.user.ID {
+		return nil, users.ErrUserNotFound
+	}
+	user := cs.user
+	return &user, nil
+}
+
+//newAuthContext returns a HandlerContext in the given session mode,
+//with its session and user stores
+func newAuthContext(t *testing.T, mode sessions.Mode) (*HandlerContext, *countingSessionStore, *countingUserStore) {
+	keys, err := sessions.ParseKeyRing("k1:first")
+	if err != nil {
+		t.Fatalf("ParseKeyRing: unexpected error %v", err)
+	}
+	sessionStore := &countingSessionStore{MemStore: sessions.NewMemStore(time.Hour, time.Hour)}
+	userStore := &countingUserStore{user: users.User{ID: 1, UserName: "alice"}}
+	ctx := &HandlerContext{
+		SessionConfig: &sessions.Config{
+			Keys:                keys,
+			Mode:                mode,
+			AccessTokenDuration: time.Minute * 15,
+			Algorithm:           sessions.AlgHS256,
+		},
+		SessionStore: sessionStore,
+		UserStore:    userStore,
+		Revocations:  sessions.NewMemRevocations(),
+	}
+	return ctx, sessionStore, userStore
+}
+
+//signIn begins a session for the user in `userStore`, with the given
+//SessionState fields, and returns its Authorization header
+func signIn(t *testing.T, ctx *HandlerContext, userStore *countingUserStore, sessState *SessionState) string {
+	user := userStore.user
+	sessState.User = &user
+	w := httptest.NewRecorder()
+	if _, err := sessions.BeginSession(ctx.SessionConfig, ctx.SessionStore, sessState, w); err != nil {
+		t.Fatalf("BeginSession: unexpected error %v", err)
+	}
+	return w.Header().Get("Authorization")
+}
+
+//newAuthRequest returns a request carrying the Authorization header
+func newAuthRequest(auth string) *http.Request {
+	r := httptest.NewRequest("GET", "/v1/news", nil)
+	r.Header.Set("Authorization", auth)
+	return r
+}
+
+func TestResolveSessionResolvesOnce(t *testing.T) {
+	ctx, sessionStore, userStore := newAuthContext(t, sessions.ModeSession)
+	auth := signIn(t, ctx, userStore, &SessionState{VerifiedAt: time.Now()})
+
+	var user *users.User
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//as a handler and the proxy's director both do
+		if _, _, err := ctx.getAuthenticatedState(r); err != nil {
+			t.Errorf("getAuthenticatedState: unexpected error %v", err)
+		}
+		user, _ = ctx.AuthenticatedUser(r)
+	})
+	limiter := NewRateLimiter(handler, ratelimit.NewMemStore(time.Minute), ctx,
+		&RateLimitRule{Path: "/", Key: KeyByUser, Policy: ratelimit.NewPolicy("default", 10, time.Minute)},
+	)
+	w := httptest.NewRecorder()
+	ctx.ResolveSession(limiter).ServeHTTP(w, newAuthRequest(auth))
+
+	if user == nil || user.ID != 1 {
+		t.Fatalf("AuthenticatedUser: got %v, want user 1", user)
+	}
+	if sessionStore.gets != 1 {
+		t.Errorf("session lookups: got %d, want 1", sessionStore.gets)
+	}
+	if userStore.loads != 0 {
+		t.Errorf("user loads: got %d, want 0 for a session that isn't stale", userStore.loads)
+	}
+}
+
+func TestRevokedUserReloaded(t *testing.T) {
+	ctx, _, userStore := newAuthContext(t, sessions.ModeToken)
+	auth := signIn(t, ctx, userStore, &SessionState{VerifiedAt: time.Now().Add(-time.Second)})
+
+	if _, _, err := ctx.getAuthenticatedState(newAuthRequest(auth)); err != nil {
+		t.Fatalf("getAuthenticatedState: unexpected error %v", err)
+	}
+	if userStore.loads != 0 {
+		t.Fatalf("user loads: got %d, want 0 before the user is revoked", userStore.loads)
+	}
+
+	userStore.user.Suspended = true
+	if err := ctx.Revocations.Revoke(userStore.user.ID); err != nil {
+		t.Fatalf("Revoke: unexpected error %v", err)
+	}
+	if _, _, err := ctx.getAuthenticatedState(newAuthRequest(auth)); err != errAccountSuspended {
+		t.Errorf("getAuthenticatedState: got %v, want errAccountSuspended", err)
+	}
+	if userStore.loads != 1 {
+		t.Errorf("user loads: got %d, want 1 after the user is revoked", userStore.loads)
+	}
+}
+
+func TestStaleSessionReloadedOnce(t *testing.T) {
+	ctx, _, userStore := newAuthContext(t, sessions.ModeSession)
+	auth := signIn(t, ctx, userStore, &SessionState{VerifiedAt: time.Now().Add(-UserReloadInterval * 2)})
+	userStore.user.Roles = []string{users.RoleModerator}
+
+	for i := 0; i < 2; i++ {
+		sessState, _, err := ctx.getAuthenticatedState(newAuthRequest(auth))
+		if err != nil {
+			t.Fatalf("getAuthenticatedState: unexpected error %v", err)
+		}
+		if !sessState.User.HasRole(users.RoleModerator) {
+			t.Errorf("request %d: got roles %v, want the reloaded user's roles", i, sessState.User.Roles)
+		}
+	}
+	if userStore.loads != 1 {
+		t.Errorf("user loads: got %d, want 1, with the reloaded user saved to the session", userStore.loads)
+	}
+}
@@ -1,13 +1,45 @@
 package handlers
 
 import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/audit"
+	"github.com/2charm/spectrum-api/pkg/exports"
+	"github.com/2charm/spectrum-api/pkg/identity"
 	"github.com/2charm/spectrum-api/pkg/sessions"
 	"github.com/2charm/spectrum-api/pkg/users"
 )
 
 //HandlerContext represents a receiver for handlers to utilize and gain context
 type HandlerContext struct {
-	SigningKey   string
-	SessionStore sessions.Store `json:"sessionStore,omitempty"`
-	UserStore    users.Store    `json:"userStore,omitempty"`
+	SessionConfig *sessions.Config
+	SessionStore  sessions.Store `json:"sessionStore,omitempty"`
+	UserStore     users.Store    `json:"userStore,omitempty"`
+	AuditStore    audit.Store    `json:"auditStore,omitempty"`
+	//Revocations records the users whose sessions must reload them
+	Revocations sessions.Revocations
+	//NewsURL and NewsClient are used to call the news service directly
+	NewsURL    *url.URL
+	NewsClient *http.Client
+	//IdentitySigner signs the user assertions sent to the news service
+	IdentitySigner *identity.Signer
+	//ExportStore holds asynchronous data export jobs until they expire
+	ExportStore exports.Store
+	//TOTPIssuer is the issuer name shown in authenticator apps
+	TOTPIssuer string
+	//MFADuration is how long a password-verified session may wait
+	//for its two-factor code before it must start over
+	MFADuration time.Duration
+}
+
+//sessionStore returns the SessionStore, with its operations traced as part of the request
+func (ctx *HandlerContext) sessionStore(r *http.Request) sessions.Store {
+	return sessions.WithContext(ctx.SessionStore, r.Context())
+}
+
+//userStore returns the UserStore, with its queries traced as part of the request
+func (ctx *HandlerContext) userStore(r *http.Request) users.Store {
+	return users.WithContext(ctx.UserStore, r.Context())
 }
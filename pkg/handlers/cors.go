@@ -10,26 +10,44 @@ with the following headers to all requests:
 
   Access-Control-Allow-Origin: *
   Access-Control-Allow-Methods: GET, PUT, POST, PATCH, DELETE
-  Access-Control-Allow-Headers: Content-Type, Authorization
-  Access-Control-Expose-Headers: Authorization
+  Access-Control-Allow-Headers: Content-Type, Authorization, Refresh-Token, X-CSRF-Token, X-Request-ID
+  Access-Control-Expose-Headers: Authorization, Refresh-Token, X-CSRF-Token, RateLimit-Limit,
+    RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After, X-Request-ID
   Access-Control-Max-Age: 600
+
+When sessions use cookies, the allowed origin must be named and
+credentials allowed, since browsers won't send cookies to "*".
 */
 
 const accessControlAllowOrigin = "*"
 const accessControlAllowMethods = "GET, PUT, POST, PATCH, DELETE"
-const accessControlAllowHeaders = "Content-Type, Authorization"
-const accessControlExposeHeaders = "Authorization"
+const accessControlAllowHeaders = "Content-Type, Authorization, Refresh-Token, X-CSRF-Token, X-Request-ID"
+const accessControlExposeHeaders = "Authorization, Refresh-Token, X-CSRF-Token, " +
+	"RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After, X-Request-ID"
 
 type ResponseHeader struct {
 	handler http.Handler
+	//origin is the allowed origin when credentials are allowed
+	origin string
 }
 
 func NewResponseHeader(handler http.Handler) *ResponseHeader {
-	return &ResponseHeader{handler}
+	return &ResponseHeader{handler: handler}
+}
+
+//NewCredentialedResponseHeader allows requests with cookies from `origin` only
+func NewCredentialedResponseHeader(handler http.Handler, origin string) *ResponseHeader {
+	return &ResponseHeader{handler: handler, origin: origin}
 }
 
 func (rh *ResponseHeader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Access-Control-Allow-Origin", accessControlAllowOrigin)
+	if rh.origin != "" {
+		w.Header().Add("Access-Control-Allow-Origin", rh.origin)
+		w.Header().Add("Access-Control-Allow-Credentials", "true")
+		w.Header().Add("Vary", "Origin")
+	} else {
+		w.Header().Add("Access-Control-Allow-Origin", accessControlAllowOrigin)
+	}
 	w.Header().Add("Access-Control-Allow-Methods", accessControlAllowMethods)
 	w.Header().Add("Access-Control-Allow-Headers", accessControlAllowHeaders)
 	w.Header().Add("Access-Control-Expose-Headers", accessControlExposeHeaders)
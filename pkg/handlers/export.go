@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/exports"
+	"github.com/2charm/spectrum-api/pkg/identity"
+	"github.com/2charm/spectrum-api/pkg/logging"
+	"github.com/2charm/spectrum-api/pkg/tracing"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+const exportsResourcePath = "/v1/users/me/exports/"
+
+//exportSyncLimit is the number of articles read above which an
+//export is generated asynchronously
+const exportSyncLimit = 1000
+
+//readingMetrics is the part of the news service's metrics
+//used to size an export
+type readingMetrics struct {
+	CategoryToNumArticles map[string]int `json:"categoryToNumArticles"`
+}
+
+//getFromNews calls the news service on behalf of the user and decodes
+//its JSON response into `value`. The call is traced as part of `reqCtx`,
+//and carries its request ID.
+func (ctx *HandlerContext) getFromNews(reqCtx context.Context, path string, user *users.User, value interface{}) error {
+	req, err := http.NewRequestWithContext(reqCtx, "GET", ctx.NewsURL.String()+path, nil)
+	if err != nil {
+		return err
+	}
+	tracing.Inject(reqCtx, req.Header)
+	requestID := logging.RequestIDFromContext(reqCtx)
+	if requestID == "" {
+		requestID = apierror.NewRequestID()
+	}
+	assertion, err := ctx.IdentitySigner.Sign(user, requestID)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(apierror.HeaderRequestID, requestID)
+	req.Header.Set(identity.HeaderUser, assertion)
+	resp, err := ctx.NewsClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("news service responded %d to %s", resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+//buildExport assembles the user's data into a zip archive of JSON files
+func (ctx *HandlerContext) buildExport(reqCtx context.Context, user *users.User, metrics json.RawMessage) ([]byte, error) {
+	signIns, err := users.WithContext(ctx.UserStore, reqCtx).GetSignIns(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	history := json.RawMessage{}
+	if err := ctx.getFromNews(reqCtx, "/v1/metrics/history", user, &history); err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"profile.json", &userWithEmail{User: user, Email: user.Email}},
+		{"sign_ins.json", signIns},
+		{"reading_metrics.json", metrics},
+		{"reading_history.json", history},
+	}
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.value); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//writeArchive responds with the export archive as a download
+func writeArchive(w http.ResponseWriter, archive []byte) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="spectrum-export.zip"`)
+	w.Write(archive)
+}
+
+//ExportHandler responds with an archive of the current user's data. Users
+//with a long reading history get a 202 Accepted with a job to poll instead.
+func (ctx *HandlerContext) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	sessState, _, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	user, err := ctx.userStore(r).GetByID(sessState.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving user", err))
+		return
+	}
+	metrics := json.RawMessage{}
+	if err := ctx.getFromNews(r.Context(), "/v1/metrics", user, &metrics); err != nil {
+		apierror.Write(w, r, apierror.Upstream("error retrieving reading metrics", err))
+		return
+	}
+	counts := &readingMetrics{}
+	if err := json.Unmarshal(metrics, counts); err != nil {
+		apierror.Write(w, r, apierror.Upstream("error decoding reading metrics", err))
+		return
+	}
+	total := 0
+	for _, count := range counts.CategoryToNumArticles {
+		total += count
+	}
+
+	if total <= exportSyncLimit {
+		archive, err := ctx.buildExport(r.Context(), user, metrics)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal("error building export", err))
+			return
+		}
+		writeArchive(w, archive)
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		apierror.Write(w, r, apierror.Internal("error starting export", err))
+		return
+	}
+	job := &exports.Job{
+		ID:        hex.EncodeToString(idBytes),
+		UserID:    user.ID,
+		Status:    exports.StatusPending,
+		CreatedAt: time.Now(),
+	}
+	job.StatusURL = exportsResourcePath + job.ID
+	if err := ctx.ExportStore.Save(job); err != nil {
+		apierror.Write(w, r, apierror.Internal("error starting export", err))
+		return
+	}
+	//the export outlives the request, but is still traced as part of it
+	jobCtx := context.WithoutCancel(r.Context())
+	go func() {
+		archive, err := ctx.buildExport(jobCtx, user, metrics)
+		done := *job
+		if err != nil {
+			slog.ErrorContext(jobCtx, "error building export", "export", job.ID, "error", err)
+			done.Status = exports.StatusFailed
+			done.Error = "error building export"
+		} else {
+			done.Status = exports.StatusReady
+			done.Archive = archive
+		}
+		if err := ctx.ExportStore.Save(&done); err != nil {
+			slog.ErrorContext(jobCtx, "error saving export", "export", job.ID, "error", err)
+		}
+	}()
+	w.Header().Set("Location", job.StatusURL)
+	respondJSON(w, r, http.StatusAccepted, job)
+}
+
+//SpecificExportHandler reports the status of an asynchronous export,
+//and responds with the archive once it's ready
+func (ctx *HandlerContext) SpecificExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	sessState, _, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, exportsResourcePath)
+	job, err := ctx.ExportStore.Get(id)
+	if err == exports.ErrJobNotFound || (err == nil && job.UserID != sessState.User.ID) {
+		apierror.Write(w, r, apierror.NotFound("export not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving export", err))
+		return
+	}
+	if job.Status == exports.StatusReady {
+		writeArchive(w, job.Archive)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, job)
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+const followingResourcePath = "/v1/users/me/following/"
+
+//defaultFeedLimit and maxFeedLimit bound the number of feed items
+const defaultFeedLimit = 50
+const maxFeedLimit = 200
+
+//maxFeedUsers is the number of users the news service's feed takes in
+//one request. Feeds of more followees are fetched in chunks and merged.
+const maxFeedUsers = 500
+
+//maxFollowing is the number of users a user may follow
+const maxFollowing = 2000
+
+//privacySettings are the privacy settings of the current user
+type privacySettings struct {
+	ShareActivity bool `json:"shareActivity"`
+}
+
+//feedEvent is one article read by a followed user, as returned by the news service
+type feedEvent struct {
+	UserID   int64     `json:"userID"`
+	Category string    `json:"category"`
+	Source   string    `json:"source"`
+	ReadOn   time.Time `json:"readOn"`
+}
+
+//feedItem is one article read by a followed user
+type feedItem struct {
+	User     *users.PublicProfile `json:"user"`
+	Category string               `json:"category"`
+	Source   string               `json:"source"`
+	ReadOn   time.Time            `json:"readOn"`
+}
+
+//publicProfiles returns the public profiles of the users
+func publicProfiles(found []*users.User) []*users.PublicProfile {
+	profiles := make([]*users.PublicProfile, len(found))
+	for i, user := range found {
+		profiles[i] = user.PublicProfile()
+	}
+	return profiles
+}
+
+//FollowingHandler lists the users the current user follows
+func (ctx *HandlerContext) FollowingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	sessState, _, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	following, err := ctx.userStore(r).GetFollowing(sessState.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving followed users", err))
+		return
+	}
+	respondJSON(w, r, http.StatusOK, publicProfiles(following))
+}
+
+//FollowersHandler lists the users following the current user
+func (ctx *HandlerContext) FollowersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	sessState, _, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	followers, err := ctx.userStore(r).GetFollowers(sessState.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving followers", err))
+		return
+	}
+	respondJSON(w, r, http.StatusOK, publicProfiles(followers))
+}
+
+//SpecificFollowingHandler follows the user with the ID at the end of the
+//path on PUT, and stops following them on DELETE
+func (ctx *HandlerContext) SpecificFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" && r.Method != "DELETE" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	sessState, _, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	followeeID, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, followingResourcePath), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("user ID must be an integer"))
+		return
+	}
+
+	if r.Method == "DELETE" {
+		if err := ctx.userStore(r).Unfollow(sessState.User.ID, followeeID); err != nil {
+			apierror.Write(w, r, apierror.Internal("error unfollowing user", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if followeeID == sessState.User.ID {
+		apierror.Write(w, r, apierror.BadRequest("users can't follow themselves"))
+		return
+	}
+	followee, err := ctx.userStore(r).GetByID(followeeID)
+	if err != nil || followee.Suspended {
+		apierror.Write(w, r, apierror.NotFound("user not found"))
+		return
+	}
+	following, err := ctx.userStore(r).GetFollowing(sessState.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving followed users", err))
+		return
+	}
+	if len(following) >= maxFollowing {
+		apierror.Write(w, r, apierror.Conflict(fmt.Sprintf("users can follow at most %d users", maxFollowing)))
+		return
+	}
+	if err := ctx.userStore(r).Follow(sessState.User.ID, followeeID); err != nil {
+		apierror.Write(w, r, apierror.Internal("error following user", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//PrivacyHandler returns the current user's privacy settings on GET and replaces them on PUT
+func (ctx *HandlerContext) PrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "PUT" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	sessState, _, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	if r.Method == "PUT" {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			apierror.Write(w, r, apierror.UnsupportedMediaType())
+			return
+		}
+		settings := &privacySettings{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(settings); err != nil {
+			apierror.Write(w, r, apierror.BadRequest("error decoding JSON"))
+			return
+		}
+		if err := ctx.userStore(r).SetShareActivity(sessState.User.ID, settings.ShareActivity); err != nil {
+			apierror.Write(w, r, apierror.Internal("error saving privacy settings", err))
+			return
+		}
+		respondJSON(w, r, http.StatusOK, settings)
+		return
+	}
+	user, err := ctx.userStore(r).GetByID(sessState.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving user", err))
+		return
+	}
+	respondJSON(w, r, http.StatusOK, &privacySettings{ShareActivity: user.ShareActivity})
+}
+
+//FeedHandler returns the articles recently read by the users the current
+//user follows. Only users who share their activity are included, and only
+//what they read since they started sharing.
+func (ctx *HandlerContext) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	sessState, _, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	limit := defaultFeedLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxFeedLimit {
+			apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("limit must be between 1 and %d", maxFeedLimit)))
+			return
+		}
+		limit = n
+	}
+
+	followees, err := ctx.userStore(r).GetSharingFollowees(sessState.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving followed users", err))
+		return
+	}
+	feed := []*feedItem{}
+	if len(followees) == 0 {
+		respondJSON(w, r, http.StatusOK, feed)
+		return
+	}
+	profiles := map[int64]*users.PublicProfile{}
+	ids := make([]string, len(followees))
+	for i, followee := range followees {
+		profiles[followee.ID] = followee.PublicProfile()
+		since := int64(0)
+		if !followee.SharingSince.IsZero() {
+			since = followee.SharingSince.Unix()
+		}
+		ids[i] = fmt.Sprintf("%d:%d", followee.ID, since)
+	}
+	events := []*feedEvent{}
+	for start := 0; start < len(ids); start += maxFeedUsers {
+		end := start + maxFeedUsers
+		if end > len(ids) {
+			end = len(ids)
+		}
+		query := url.Values{}
+		query.Set("userIDs", strings.Join(ids[start:end], ","))
+		query.Set("limit", strconv.Itoa(limit))
+		chunk := []*feedEvent{}
+		if err := ctx.getFromNews(r.Context(), "/v1/metrics/feed?"+query.Encode(), sessState.User, &chunk); err != nil {
+			apierror.Write(w, r, apierror.Upstream("error retrieving feed", err))
+			return
+		}
+		events = append(events, chunk...)
+	}
+	//each chunk is in order, but the merged chunks have to be sorted
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ReadOn.After(events[j].ReadOn)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	for _, event := range events {
+		profile, found := profiles[event.UserID]
+		if !found {
+			continue
+		}
+		feed = append(feed, &feedItem{
+			User:     profile,
+			Category: event.Category,
+			Source:   event.Source,
+			ReadOn:   event.ReadOn,
+		})
+	}
+	respondJSON(w, r, http.StatusOK, feed)
+}
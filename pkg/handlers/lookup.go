@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+//minSearchPrefix is the shortest prefix user search accepts
+const minSearchPrefix = 2
+
+//defaultSearchLimit and maxSearchLimit bound the number of search results
+const defaultSearchLimit = 10
+const maxSearchLimit = 50
+
+//availability reports whether the requested user name and email are free
+type availability struct {
+	UserName *bool `json:"userName,omitempty"`
+	Email    *bool `json:"email,omitempty"`
+}
+
+//AvailableHandler reports whether a user name and email can be used to
+//sign up. It returns only true or false for each, never account details.
+func (ctx *HandlerContext) AvailableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	query := r.URL.Query()
+	userName := query.Get("userName")
+	email := query.Get("email")
+	if userName == "" && email == "" {
+		apierror.Write(w, r, apierror.BadRequest("userName or email is required"))
+		return
+	}
+
+	result := &availability{}
+	if userName != "" {
+		_, err := ctx.userStore(r).GetByUserName(userName)
+		if err != nil && err != users.ErrUserNotFound {
+			apierror.Write(w, r, apierror.Internal("error checking user name", err))
+			return
+		}
+		available := err == users.ErrUserNotFound
+		result.UserName = &available
+	}
+	if email != "" {
+		_, err := ctx.userStore(r).GetByEmail(email)
+		if err != nil && err != users.ErrUserNotFound {
+			apierror.Write(w, r, apierror.Internal("error checking email", err))
+			return
+		}
+		available := err == users.ErrUserNotFound
+		result.Email = &available
+	}
+	respondJSON(w, r, http.StatusOK, result)
+}
+
+//UserSearchHandler returns the public profiles of users whose user name
+//or name starts with the "q" query parameter
+func (ctx *HandlerContext) UserSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	if _, _, err := ctx.getAuthenticatedState(r); err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	prefix := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(prefix) < minSearchPrefix {
+		apierror.Write(w, r, apierror.BadRequest("q must be at least 2 characters"))
+		return
+	}
+	limit := defaultSearchLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			apierror.Write(w, r, apierror.BadRequest("limit must be between 1 and 50"))
+			return
+		}
+		limit = n
+	}
+	found, err := ctx.userStore(r).SearchByPrefix(prefix, limit)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error searching users", err))
+		return
+	}
+	respondJSON(w, r, http.StatusOK, publicProfiles(found))
+}
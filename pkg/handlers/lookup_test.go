@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+//lookupUserStore is a users.Store whose lookups by name and email
+//return `user`, or `err` when it's set
+type lookupUserStore struct {
+	users.Store
+	user *users.User
+	err  error
+}
+
+func (ls *lookupUserStore) GetByUserName(username string) (*users.User, error) {
+	return ls.get()
+}
+
+func (ls *lookupUserStore) GetByEmail(email string) (*users.User, error) {
+	return ls.get()
+}
+
+func (ls *lookupUserStore) get() (*users.User, error) {
+	if ls.err != nil {
+		return nil, ls.err
+	}
+	if ls.user == nil {
+		return nil, users.ErrUserNotFound
+	}
+	return ls.user, nil
+}
+
+//checkAvailable requests the availability of a user name and email
+func checkAvailable(store users.Store) *httptest.ResponseRecorder {
+	ctx := &HandlerContext{UserStore: store}
+	w := httptest.NewRecorder()
+	ctx.AvailableHandler(w, httptest.NewRequest("GET", "/v1/users/available?userName=alice&email=alice%40example.com", nil))
+	return w
+}
+
+func TestAvailableHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		store     *lookupUserStore
+		available bool
+	}{
+		{"free", &lookupUserStore{}, true},
+		{"taken", &lookupUserStore{user: &users.User{ID: 1}}, false},
+	}
+	for _, c := range cases {
+		w := checkAvailable(c.store)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want %d", c.name, w.Code, http.StatusOK)
+		}
+		result := &availability{}
+		if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+			t.Fatalf("%s: error decoding response: %v", c.name, err)
+		}
+		if result.UserName == nil || *result.UserName != c.available || result.Email == nil || *result.Email != c.available {
+			t.Errorf("%s: got %s, want both available=%t", c.name, w.Body.String(), c.available)
+		}
+	}
+}
+
+func TestAvailableHandlerStoreError(t *testing.T) {
+	w := checkAvailable(&lookupUserStore{err: errors.New("connection refused")})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("failing store: got status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	//nothing listens on port 1, so every query fails to connect
+	db, err := sql.Open("mysql", "user:password@tcp(127.0.0.1:1)/users?timeout=1s")
+	if err != nil {
+		t.Fatalf("sql.Open: unexpected error %v", err)
+	}
+	defer db.Close()
+	w = checkAvailable(users.NewMySQLStore(db))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unreachable MySQL: got status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/sessions"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+//totpCode is the request body for confirming, disabling
+//or completing sign-in with two-factor authentication
+type totpCode struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty"`
+}
+
+//totpEnrollment is returned when a user enrolls in two-factor authentication
+type totpEnrollment struct {
+	Secret          string   `json:"secret"`
+	ProvisioningURI string   `json:"provisioningURI"`
+	RecoveryCodes   []string `json:"recoveryCodes"`
+}
+
+//decodeTOTPCode decodes a JSON totpCode from the request body
+func decodeTOTPCode(w http.ResponseWriter, r *http.Request) (*totpCode, bool) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		apierror.Write(w, r, apierror.UnsupportedMediaType())
+		return nil, false
+	}
+	code := &totpCode{}
+	if err := json.NewDecoder(r.Body).Decode(code); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error decoding JSON"))
+		return nil, false
+	}
+	return code, true
+}
+
+//errInvalidMFACode is returned when a two-factor or recovery code is wrong
+var errInvalidMFACode = apierror.New(http.StatusUnauthorized, apierror.CodeInvalidMFACode, "invalid two-factor code")
+
+//maxMFAFailures is the number of invalid codes that end a pending sign-in
+const maxMFAFailures = 5
+
+//verifyTOTP checks a TOTP code, or consumes a recovery code, for the user
+func (ctx *HandlerContext) verifyTOTP(r *http.Request, user *users.User, code *totpCode) error {
+	if code.RecoveryCode != "" {
+		return ctx.userStore(r).UseRecoveryCode(user.ID, users.HashRecoveryCode(code.RecoveryCode))
+	}
+	return ctx.useTOTP(r, user, code.Code)
+}
+
+//useTOTP checks a TOTP code for the user and records its time step,
+//so the same code can't be used twice
+func (ctx *HandlerContext) useTOTP(r *http.Request, user *users.User, code string) error {
+	step, err := users.MatchTOTP(user.TOTPSecret, code, time.Now())
+	if err != nil {
+		return err
+	}
+	return ctx.userStore(r).UseTOTPStep(user.ID, step)
+}
+
+//TOTPHandler handles two-factor enrollment for the current user.
+//POST enrolls a new secret, PUT confirms it with a code and
+//DELETE disables two-factor authentication.
+func (ctx *HandlerContext) TOTPHandler(w http.ResponseWriter, r *http.Request) {
+	sessState, _, err := ctx.getAuthenticatedState(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	user, err := ctx.userStore(r).GetByID(sessState.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving user", err))
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		if user.TOTPEnabled {
+			apierror.Write(w, r, apierror.Conflict("two-factor authentication is already enabled"))
+			return
+		}
+		secret, err := users.NewTOTPSecret()
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal("error generating secret", err))
+			return
+		}
+		codes, err := users.NewRecoveryCodes()
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal("error generating recovery codes", err))
+			return
+		}
+		hashes := make([]string, len(codes))
+		for i, code := range codes {
+			hashes[i] = users.HashRecoveryCode(code)
+		}
+		if err := ctx.userStore(r).SetTOTP(user.ID, secret, false); err != nil {
+			apierror.Write(w, r, apierror.Internal("error saving secret", err))
+			return
+		}
+		if err := ctx.userStore(r).SetRecoveryCodes(user.ID, hashes); err != nil {
+			apierror.Write(w, r, apierror.Internal("error saving recovery codes", err))
+			return
+		}
+		respondJSON(w, r, http.StatusCreated, &totpEnrollment{
+			Secret:          secret,
+			ProvisioningURI: users.TOTPProvisioningURI(ctx.TOTPIssuer, user.Email, secret),
+			RecoveryCodes:   codes,
+		})
+	case "PUT":
+		code, ok := decodeTOTPCode(w, r)
+		if !ok {
+			return
+		}
+		if user.TOTPSecret == "" {
+			apierror.Write(w, r, apierror.Conflict("two-factor authentication is not enrolled"))
+			return
+		}
+		if err := ctx.useTOTP(r, user, code.Code); err != nil {
+			apierror.Write(w, r, errInvalidMFACode)
+			return
+		}
+		if err := ctx.userStore(r).SetTOTP(user.ID, user.TOTPSecret, true); err != nil {
+			apierror.Write(w, r, apierror.Internal("error enabling two-factor authentication", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		code, ok := decodeTOTPCode(w, r)
+		if !ok {
+			return
+		}
+		if !user.TOTPEnabled {
+			apierror.Write(w, r, apierror.Conflict("two-factor authentication is not enabled"))
+			return
+		}
+		if err := ctx.verifyTOTP(r, user, code); err != nil {
+			apierror.Write(w, r, errInvalidMFACode)
+			return
+		}
+		if err := ctx.userStore(r).SetTOTP(user.ID, "", false); err != nil {
+			apierror.Write(w, r, apierror.Internal("error disabling two-factor authentication", err))
+			return
+		}
+		if err := ctx.userStore(r).SetRecoveryCodes(user.ID, nil); err != nil {
+			apierror.Write(w, r, apierror.Internal("error removing recovery codes", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+	}
+}
+
+//MFAHandler completes a sign-in that is waiting for a two-factor code,
+//upgrading the pending session to a full session. The pending session
+//ends after maxMFAFailures invalid codes.
+func (ctx *HandlerContext) MFAHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	sid, err := sessions.GetState(r, ctx.SessionConfig, ctx.SessionStore, &SessionState{})
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("no pending sign-in"))
+		return
+	}
+	//read the state from the store even in token mode, where the access
+	//token can't carry the failure count and outlives an ended sign-in
+	pending := &SessionState{}
+	if err := ctx.sessionStore(r).Get(sid, pending); err != nil || !pending.MFAPending || pending.User == nil {
+		apierror.Write(w, r, apierror.Unauthenticated("no pending sign-in"))
+		return
+	}
+	if time.Now().After(pending.MFAExpires) {
+		ctx.endSession(r, pending.User.ID, sid)
+		apierror.Write(w, r, apierror.Unauthenticated("pending sign-in expired"))
+		return
+	}
+	code, ok := decodeTOTPCode(w, r)
+	if !ok {
+		return
+	}
+	user, err := ctx.userStore(r).GetByID(pending.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving user", err))
+		return
+	}
+	if err := ctx.verifyTOTP(r, user, code); err != nil {
+		pending.MFAFailures++
+		if pending.MFAFailures >= maxMFAFailures {
+			ctx.endSession(r, user.ID, sid)
+			apierror.Write(w, r, apierror.Unauthenticated("too many invalid two-factor codes, sign in again"))
+			return
+		}
+		if err := ctx.sessionStore(r).Save(sid, pending); err != nil {
+			apierror.Write(w, r, apierror.Internal("error saving pending sign-in", err))
+			return
+		}
+		apierror.Write(w, r, errInvalidMFACode)
+		return
+	}
+
+	//issue a new session ID rather than reusing the pending one
+	if err := ctx.endSession(r, user.ID, sid); err != nil {
+		apierror.Write(w, r, apierror.Internal("error ending pending session", err))
+		return
+	}
+	sessState := &SessionState{
+		User:                 user,
+		PasswordResetPending: user.PasswordResetRequired,
+	}
+	_, err = ctx.beginSession(w, r, sessState)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error starting new session", err))
+		return
+	}
+	respondJSON(w, r, http.StatusCreated, user)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/sessions"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+//PasswordHandler changes the current user's password. It also accepts
+//sessions that are waiting for a forced password reset, and ends every
+//other session of the user before starting a new one.
+func (ctx *HandlerContext) PasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	sessState := &SessionState{}
+	_, err := sessions.GetState(r, ctx.SessionConfig, ctx.SessionStore, sessState)
+	if err != nil || sessState.User == nil || sessState.MFAPending {
+		apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+		return
+	}
+	if sessState.ImpersonatorID != 0 {
+		apierror.Write(w, r, apierror.Forbidden("passwords can't be changed while impersonating"))
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		apierror.Write(w, r, apierror.UnsupportedMediaType())
+		return
+	}
+	change := &users.PasswordChange{}
+	if err := json.NewDecoder(r.Body).Decode(change); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error decoding JSON"))
+		return
+	}
+
+	user, err := ctx.userStore(r).GetByID(sessState.User.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error retrieving user", err))
+		return
+	}
+	if user.Suspended {
+		apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeAccountSuspended, "account suspended"))
+		return
+	}
+	if err := user.Authenticate(change.CurrentPassword); err != nil {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "current password is not correct"))
+		return
+	}
+	if err := change.Validate(user); err != nil {
+		apierror.Write(w, r, validationError(err))
+		return
+	}
+	if err := user.SetPassword(change.NewPassword); err != nil {
+		apierror.Write(w, r, apierror.Internal("error hashing password", err))
+		return
+	}
+	if err := ctx.userStore(r).UpdatePassword(user.ID, user.PassHash); err != nil {
+		apierror.Write(w, r, apierror.Internal("error saving password", err))
+		return
+	}
+	if err := ctx.userStore(r).SetPasswordResetRequired(user.ID, false); err != nil {
+		apierror.Write(w, r, apierror.Internal("error saving password", err))
+		return
+	}
+	user.PasswordResetRequired = false
+
+	if err := ctx.endUserSessions(r, user.ID); err != nil {
+		apierror.Write(w, r, apierror.Internal("error ending sessions", err))
+		return
+	}
+	newState := &SessionState{
+		User: user,
+	}
+	if _, err := ctx.beginSession(w, r, newState); err != nil {
+		apierror.Write(w, r, apierror.Internal("error starting new session", err))
+		return
+	}
+	respondJSON(w, r, http.StatusOK, user)
+}
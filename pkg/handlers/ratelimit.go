@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/ratelimit"
+	"github.com/2charm/spectrum-api/pkg/util"
+)
+
+//RateLimitKey selects what a RateLimitRule counts requests by
+type RateLimitKey int
+
+const (
+	//KeyByIP counts the requests from each client IP address
+	KeyByIP RateLimitKey = iota
+	//KeyByUser counts the requests of each signed-in user, and
+	//falls back to the client IP address for anonymous requests
+	KeyByUser
+)
+
+//RateLimitRule applies a rate limit policy to the requests for a path
+type RateLimitRule struct {
+	//Path is matched exactly, or as a prefix when it ends in "/"
+	Path string
+	//Methods limits the rule to these methods, or every method if empty
+	Methods []string
+	Key     RateLimitKey
+	Policy  *ratelimit.Policy
+}
+
+//matches returns true if the rule applies to the request
+func (rule *RateLimitRule) matches(r *http.Request) bool {
+	if strings.HasSuffix(rule.Path, "/") {
+		if !strings.HasPrefix(r.URL.Path, rule.Path) {
+			return false
+		}
+	} else if r.URL.Path != rule.Path {
+		return false
+	}
+	if len(rule.Methods) == 0 {
+		return true
+	}
+	for _, method := range rule.Methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+//RateLimiter limits the rate of requests to `handler` using the first
+//rule that matches each request. Requests no rule matches aren't limited.
+type RateLimiter struct {
+	handler http.Handler
+	store   ratelimit.Store
+	ctx     *HandlerContext
+	rules   []*RateLimitRule
+}
+
+//NewRateLimiter constructs a RateLimiter keeping its buckets in `store`.
+//`ctx` is used to find the signed-in user for KeyByUser rules.
+func NewRateLimiter(handler http.Handler, store ratelimit.Store, ctx *HandlerContext, rules ...*RateLimitRule) *RateLimiter {
+	return &RateLimiter{handler: handler, store: store, ctx: ctx, rules: rules}
+}
+
+func (rl *RateLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var rule *RateLimitRule
+	for _, candidate := range rl.rules {
+		if candidate.matches(r) {
+			rule = candidate
+			break
+		}
+	}
+	if rule == nil {
+		rl.handler.ServeHTTP(w, r)
+		return
+	}
+
+	key := "ip:" + util.ClientIP(r)
+	if rule.Key == KeyByUser {
+		if sessState, _, err := rl.ctx.getAuthenticatedState(r); err == nil {
+			key = "user:" + strconv.FormatInt(sessState.User.ID, 10)
+		}
+	}
+	result, err := rl.store.Take(rule.Policy, key)
+	if err != nil {
+		//let requests through rather than fail every one while the store is down
+		slog.ErrorContext(r.Context(), "error checking rate limit", "policy", rule.Policy.Name, "error", err)
+		rl.handler.ServeHTTP(w, r)
+		return
+	}
+
+	policy := rule.Policy
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", seconds(result.Reset))
+	w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Period.Seconds())))
+	if !result.Allowed {
+		w.Header().Set("Retry-After", seconds(result.RetryAfter))
+		apierror.Write(w, r, apierror.TooManyRequests("too many requests, try again later"))
+		return
+	}
+	rl.handler.ServeHTTP(w, r)
+}
+
+//seconds formats the duration as a whole number of seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
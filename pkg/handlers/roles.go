@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+)
+
+//contextKey is the type of keys for values handlers keep in a request context
+type contextKey int
+
+const (
+	//sessionStateKey is the request context key of the SessionState
+	//found by RequireRole
+	sessionStateKey contextKey = iota
+	//resolvedStateKey is the request context key of the resolvedState
+	//added by ResolveSession
+	resolvedStateKey
+)
+
+//RequireRole wraps `handler` so that it only serves signed-in users
+//who have been granted at least one of the given roles. The session
+//state is passed to `handler` in the request context.
+func (ctx *HandlerContext) RequireRole(handler http.Handler, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessState, _, err := ctx.getAuthenticatedState(r)
+		if err != nil {
+			apierror.Write(w, r, apierror.Unauthenticated("user not authenticated"))
+			return
+		}
+		for _, role := range roles {
+			if sessState.User.HasRole(role) {
+				r = r.WithContext(context.WithValue(r.Context(), sessionStateKey, sessState))
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+		apierror.Write(w, r, apierror.Forbidden("user is not authorized"))
+	})
+}
+
+//getContextState returns the SessionState that RequireRole
+//added to the request context
+func getContextState(r *http.Request) *SessionState {
+	sessState, _ := r.Context().Value(sessionStateKey).(*SessionState)
+	return sessState
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-redis/redis"
+)
+
+//SQL checks that the database answers a ping
+func SQL(db *sql.DB) CheckFunc {
+	return func(ctx context.Context) (interface{}, error) {
+		return nil, db.PingContext(ctx)
+	}
+}
+
+//Redis checks that the redis server answers a ping
+func Redis(client *redis.Client) CheckFunc {
+	return func(ctx context.Context) (interface{}, error) {
+		return nil, client.WithContext(ctx).Ping().Err()
+	}
+}
+
+//Service checks another service's readiness endpoint at `url`. The
+//service's own report is included as the details of the check.
+func Service(client *http.Client, url string) CheckFunc {
+	return func(ctx context.Context) (interface{}, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		report := &Report{}
+		if err := json.NewDecoder(resp.Body).Decode(report); err != nil {
+			return nil, fmt.Errorf("error decoding readiness report: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return report, fmt.Errorf("service is %s", report.Status)
+		}
+		return report, nil
+	}
+}
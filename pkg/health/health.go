@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+)
+
+//Statuses of a check and of the service as a whole
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+//CheckFunc checks a dependency, returning an error if it's unusable.
+//It may return details to include in the report.
+type CheckFunc func(ctx context.Context) (interface{}, error)
+
+//Check is a named dependency check
+type Check struct {
+	Name string
+	//Critical checks make the service unready when they fail,
+	//the others only mark it as degraded
+	Critical bool
+	Check    CheckFunc
+}
+
+//Result is the outcome of a single check
+type Result struct {
+	Status    string      `json:"status"`
+	Critical  bool        `json:"critical"`
+	Error     string      `json:"error,omitempty"`
+	LatencyMS int64       `json:"latencyMs"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+//Report is the outcome of every check
+type Report struct {
+	Status string             `json:"status"`
+	Checks map[string]*Result `json:"checks"`
+}
+
+//Checker runs a service's dependency checks
+type Checker struct {
+	//Timeout bounds how long each check may take
+	Timeout time.Duration
+	checks  []*Check
+}
+
+//NewChecker constructs a Checker running the given checks
+func NewChecker(timeout time.Duration, checks ...*Check) *Checker {
+	return &Checker{Timeout: timeout, checks: checks}
+}
+
+//Run runs every check concurrently and reports their results
+func (c *Checker) Run(ctx context.Context) *Report {
+	report := &Report{Status: StatusOK, Checks: map[string]*Result{}}
+	mx := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check *Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+			start := time.Now()
+			details, err := check.Check(checkCtx)
+			result := &Result{
+				Status:    StatusOK,
+				Critical:  check.Critical,
+				LatencyMS: time.Since(start).Milliseconds(),
+				Details:   details,
+			}
+			mx.Lock()
+			defer mx.Unlock()
+			if err != nil {
+				result.Status = StatusUnavailable
+				result.Error = err.Error()
+				if check.Critical {
+					report.Status = StatusUnavailable
+				} else if report.Status == StatusOK {
+					report.Status = StatusDegraded
+				}
+			}
+			report.Checks[check.Name] = result
+		}(check)
+	}
+	wg.Wait()
+	return report
+}
+
+//ReadinessHandler reports whether the service's dependencies are usable.
+//It responds with 503 if a critical check fails, so the service isn't
+//sent traffic, and 200 otherwise, even when it's degraded.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	report := c.Run(r.Context())
+	status := http.StatusOK
+	if report.Status == StatusUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+//LivenessHandler reports that the service is running. It checks no
+//dependencies, so a dependency outage doesn't get the service restarted.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	writeReport(w, http.StatusOK, &Report{Status: StatusOK, Checks: map[string]*Result{}})
+}
+
+//writeReport writes the report as JSON with the given status code
+func writeReport(w http.ResponseWriter, status int, report *Report) {
+	buffer, err := json.Marshal(report)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buffer)
+}
@@ -0,0 +1,165 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+//HeaderUser is the header carrying the signed user assertion
+//from the gateway to the services behind it
+const HeaderUser = "X-User"
+
+//AlgHS256 signs assertions with HMAC-SHA256 using a key shared by both services
+const AlgHS256 = "HS256"
+
+//AlgEdDSA signs assertions with an Ed25519 private key held by the gateway.
+//Services only need the public key to verify them.
+const AlgEdDSA = "EdDSA"
+
+//ErrNoAssertion is returned when a request has no user assertion
+var ErrNoAssertion = errors.New("no user assertion in request")
+
+//ErrInvalidAssertion is returned when an assertion is malformed,
+//has an invalid signature or was issued for another request
+var ErrInvalidAssertion = errors.New("invalid user assertion")
+
+//ErrExpiredAssertion is returned when an assertion has expired
+var ErrExpiredAssertion = errors.New("user assertion expired")
+
+//claims are the signed contents of an assertion
+type claims struct {
+	Alg       string          `json:"alg"`
+	User      json.RawMessage `json:"user"`
+	IssuedAt  int64           `json:"iat"`
+	ExpiresAt int64           `json:"exp"`
+	RequestID string          `json:"rid"`
+}
+
+var encoding = base64.RawURLEncoding
+
+//Signer issues short-lived assertions of a user's identity, of the form
+//"<base64url claims>.<base64url signature>". The claims hold the user
+//JSON, the issue and expiry times and the ID of the request.
+type Signer struct {
+	alg     string
+	hmacKey []byte
+	edKey   ed25519.PrivateKey
+	//TTL is how long an assertion is valid after it's issued
+	TTL time.Duration
+}
+
+//NewHMACSigner constructs a Signer using HMAC-SHA256 with the shared key
+func NewHMACSigner(key []byte, ttl time.Duration) *Signer {
+	return &Signer{alg: AlgHS256, hmacKey: key, TTL: ttl}
+}
+
+//NewEd25519Signer constructs a Signer using the Ed25519 private key
+func NewEd25519Signer(key ed25519.PrivateKey, ttl time.Duration) *Signer {
+	return &Signer{alg: AlgEdDSA, edKey: key, TTL: ttl}
+}
+
+//Sign returns an assertion of the user's identity for the request with the given ID
+func (s *Signer) Sign(user interface{}, requestID string) (string, error) {
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	payload, err := json.Marshal(&claims{
+		Alg:       s.alg,
+		User:      userJSON,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.TTL).Unix(),
+		RequestID: requestID,
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := encoding.EncodeToString(payload)
+	var signature []byte
+	if s.alg == AlgEdDSA {
+		signature = ed25519.Sign(s.edKey, []byte(encoded))
+	} else {
+		signature = hmacSHA256(s.hmacKey, []byte(encoded))
+	}
+	return encoded + "." + encoding.EncodeToString(signature), nil
+}
+
+//Verifier checks assertions issued by a Signer
+type Verifier struct {
+	alg     string
+	hmacKey []byte
+	edKey   ed25519.PublicKey
+	//Skew is the clock difference tolerated between the two services
+	Skew time.Duration
+}
+
+//NewHMACVerifier constructs a Verifier using HMAC-SHA256 with the shared key
+func NewHMACVerifier(key []byte, skew time.Duration) *Verifier {
+	return &Verifier{alg: AlgHS256, hmacKey: key, Skew: skew}
+}
+
+//NewEd25519Verifier constructs a Verifier using the Ed25519 public key
+func NewEd25519Verifier(key ed25519.PublicKey, skew time.Duration) *Verifier {
+	return &Verifier{alg: AlgEdDSA, edKey: key, Skew: skew}
+}
+
+//Verify checks the assertion's signature and expiry, checks that it was
+//issued for the request with the given ID, and populates `user` with
+//the user JSON it carries
+func (v *Verifier) Verify(assertion string, requestID string, user interface{}) error {
+	if assertion == "" {
+		return ErrNoAssertion
+	}
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 2 {
+		return ErrInvalidAssertion
+	}
+	signature, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrInvalidAssertion
+	}
+	if v.alg == AlgEdDSA {
+		if len(v.edKey) != ed25519.PublicKeySize || !ed25519.Verify(v.edKey, []byte(parts[0]), signature) {
+			return ErrInvalidAssertion
+		}
+	} else if !hmac.Equal(hmacSHA256(v.hmacKey, []byte(parts[0])), signature) {
+		return ErrInvalidAssertion
+	}
+
+	payload, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidAssertion
+	}
+	c := &claims{}
+	if err := json.Unmarshal(payload, c); err != nil || c.Alg != v.alg {
+		return ErrInvalidAssertion
+	}
+	now := time.Now()
+	if now.Add(-v.Skew).Unix() > c.ExpiresAt {
+		return ErrExpiredAssertion
+	}
+	if now.Add(v.Skew).Unix() < c.IssuedAt {
+		return ErrInvalidAssertion
+	}
+	if c.RequestID != requestID {
+		return ErrInvalidAssertion
+	}
+	if err := json.Unmarshal(c.User, user); err != nil {
+		return ErrInvalidAssertion
+	}
+	return nil
+}
+
+//hmacSHA256 returns the HMAC-SHA256 of the message with the key
+func hmacSHA256(key []byte, message []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(message)
+	return h.Sum(nil)
+}
@@ -0,0 +1,154 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+}
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+//signClaims signs arbitrary claims with HMAC-SHA256, so tests can
+//build assertions a Signer wouldn't issue
+func signClaims(t *testing.T, c *claims) string {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("json.Marshal: unexpected error %v", err)
+	}
+	encoded := encoding.EncodeToString(payload)
+	return encoded + "." + encoding.EncodeToString(hmacSHA256(testKey, []byte(encoded)))
+}
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey: unexpected error %v", err)
+	}
+	pairs := map[string]struct {
+		signer   *Signer
+		verifier *Verifier
+	}{
+		AlgHS256: {NewHMACSigner(testKey, time.Minute), NewHMACVerifier(testKey, time.Second)},
+		AlgEdDSA: {NewEd25519Signer(priv, time.Minute), NewEd25519Verifier(pub, time.Second)},
+	}
+	for alg, pair := range pairs {
+		assertion, err := pair.signer.Sign(&testUser{ID: 7, Email: "a@example.com"}, "req-1")
+		if err != nil {
+			t.Fatalf("%s: Sign: unexpected error %v", alg, err)
+		}
+		user := &testUser{}
+		if err := pair.verifier.Verify(assertion, "req-1", user); err != nil {
+			t.Errorf("%s: Verify: unexpected error %v", alg, err)
+		}
+		if user.ID != 7 || user.Email != "a@example.com" {
+			t.Errorf("%s: Verify: got user %+v", alg, user)
+		}
+		if err := pair.verifier.Verify(assertion, "req-2", &testUser{}); err != ErrInvalidAssertion {
+			t.Errorf("%s: wrong request ID: got %v, want ErrInvalidAssertion", alg, err)
+		}
+	}
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	expired, err := NewHMACSigner(testKey, -10*time.Second).Sign(&testUser{ID: 1}, "req-1")
+	if err != nil {
+		t.Fatalf("Sign: unexpected error %v", err)
+	}
+	if err := NewHMACVerifier(testKey, 5*time.Second).Verify(expired, "req-1", &testUser{}); err != ErrExpiredAssertion {
+		t.Errorf("expired beyond the skew: got %v, want ErrExpiredAssertion", err)
+	}
+	if err := NewHMACVerifier(testKey, 30*time.Second).Verify(expired, "req-1", &testUser{}); err != nil {
+		t.Errorf("expired within the skew: unexpected error %v", err)
+	}
+}
+
+func TestVerifyIssuedInFuture(t *testing.T) {
+	now := time.Now()
+	future := func(offset time.Duration) string {
+		return signClaims(t, &claims{
+			Alg:       AlgHS256,
+			User:      json.RawMessage(`{"id":1}`),
+			IssuedAt:  now.Add(offset).Unix(),
+			ExpiresAt: now.Add(offset + time.Minute).Unix(),
+			RequestID: "req-1",
+		})
+	}
+	verifier := NewHMACVerifier(testKey, 5*time.Second)
+	if err := verifier.Verify(future(time.Minute), "req-1", &testUser{}); err != ErrInvalidAssertion {
+		t.Errorf("issued beyond the skew: got %v, want ErrInvalidAssertion", err)
+	}
+	if err := verifier.Verify(future(2*time.Second), "req-1", &testUser{}); err != nil {
+		t.Errorf("issued within the skew: unexpected error %v", err)
+	}
+}
+
+func TestVerifyWrongAlgorithm(t *testing.T) {
+	now := time.Now()
+	//validly signed with the shared key, but claiming another algorithm
+	mislabeled := signClaims(t, &claims{
+		Alg:       AlgEdDSA,
+		User:      json.RawMessage(`{"id":1}`),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		RequestID: "req-1",
+	})
+	if err := NewHMACVerifier(testKey, 0).Verify(mislabeled, "req-1", &testUser{}); err != ErrInvalidAssertion {
+		t.Errorf("claims with another algorithm: got %v, want ErrInvalidAssertion", err)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey: unexpected error %v", err)
+	}
+	signed, err := NewEd25519Signer(priv, time.Minute).Sign(&testUser{ID: 1}, "req-1")
+	if err != nil {
+		t.Fatalf("Sign: unexpected error %v", err)
+	}
+	if err := NewHMACVerifier(testKey, 0).Verify(signed, "req-1", &testUser{}); err != ErrInvalidAssertion {
+		t.Errorf("EdDSA assertion to an HMAC verifier: got %v, want ErrInvalidAssertion", err)
+	}
+	hmacSigned, err := NewHMACSigner(testKey, time.Minute).Sign(&testUser{ID: 1}, "req-1")
+	if err != nil {
+		t.Fatalf("Sign: unexpected error %v", err)
+	}
+	if err := NewEd25519Verifier(pub, 0).Verify(hmacSigned, "req-1", &testUser{}); err != ErrInvalidAssertion {
+		t.Errorf("HMAC assertion to an EdDSA verifier: got %v, want ErrInvalidAssertion", err)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	signer := NewHMACSigner(testKey, time.Minute)
+	verifier := NewHMACVerifier(testKey, 0)
+	assertion, err := signer.Sign(&testUser{ID: 1}, "req-1")
+	if err != nil {
+		t.Fatalf("Sign: unexpected error %v", err)
+	}
+	parts := strings.Split(assertion, ".")
+	payload, _ := encoding.DecodeString(parts[0])
+	tampered := strings.Replace(string(payload), `"id":1`, `"id":2`, 1)
+
+	cases := map[string]string{
+		"tampered payload": encoding.EncodeToString([]byte(tampered)) + "." + parts[1],
+		"other key":        parts[0] + "." + encoding.EncodeToString(hmacSHA256([]byte("other key"), []byte(parts[0]))),
+		"no signature":     parts[0],
+		"empty signature":  parts[0] + ".",
+		"extra part":       assertion + ".x",
+		"bad encoding":     parts[0] + ".not*base64",
+	}
+	for name, c := range cases {
+		if err := verifier.Verify(c, "req-1", &testUser{}); err != ErrInvalidAssertion {
+			t.Errorf("%s: got %v, want ErrInvalidAssertion", name, err)
+		}
+	}
+	if err := verifier.Verify("", "req-1", &testUser{}); err != ErrNoAssertion {
+		t.Errorf("empty assertion: got %v, want ErrNoAssertion", err)
+	}
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/util"
+)
+
+//validRequestID matches the request IDs accepted from clients and the
+//gateway, so a client can't inject arbitrary text into the logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+//RequestID gives each request an ID, keeping a valid X-Request-ID sent
+//with it or generating one. The ID is set on the request, so a proxy
+//forwards it, on the response and in the request's context for logging.
+type RequestID struct {
+	handler http.Handler
+}
+
+//NewRequestID constructs a RequestID
+func NewRequestID(handler http.Handler) *RequestID {
+	return &RequestID{handler: handler}
+}
+
+func (ri *RequestID) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(apierror.HeaderRequestID)
+	if !validRequestID.MatchString(id) {
+		id = apierror.NewRequestID()
+		r.Header.Set(apierror.HeaderRequestID, id)
+	}
+	w.Header().Set(apierror.HeaderRequestID, id)
+	ri.handler.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+}
+
+//AccessLog logs each request to `handler` once it has been served, with
+//its status, size and latency. Health checks are logged at the debug level.
+type AccessLog struct {
+	handler http.Handler
+}
+
+//NewAccessLog constructs an AccessLog
+func NewAccessLog(handler http.Handler) *AccessLog {
+	return &AccessLog{handler: handler}
+}
+
+func (al *AccessLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := util.NewStatusRecorder(w)
+	al.handler.ServeHTTP(recorder, r)
+
+	level := slog.LevelInfo
+	if recorder.Status >= 500 {
+		level = slog.LevelError
+	} else if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+		level = slog.LevelDebug
+	}
+	slog.Default().Log(r.Context(), level, "request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("query", RedactQuery(r.URL.RawQuery)),
+		slog.Int("status", recorder.Status),
+		slog.Int("bytes", recorder.Bytes),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.String("client_ip", util.ClientIP(r)),
+		slog.String("user_agent", r.UserAgent()),
+	)
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+//Setup makes a JSON logger for `service` the default for both slog and
+//the log package. Records below `level`, one of debug, info, warn or
+//error, are dropped. Messages and attributes are redacted, and records
+//logged with a request's context carry its request ID and trace ID.
+func Setup(service string, level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("unknown log level %s, must be debug, info, warn or error", level)
+	}
+	handler := &contextHandler{handler: slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		Level:       lvl,
+		ReplaceAttr: redactAttr,
+	})}
+	slog.SetDefault(slog.New(handler).With("service", service))
+	return nil
+}
+
+//Fatal logs the message at the error level and exits
+func Fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+//contextHandler adds the request and trace IDs in the context to each
+//record, and redacts the record's message
+type contextHandler struct {
+	handler slog.Handler
+}
+
+func (ch *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return ch.handler.Enabled(ctx, level)
+}
+
+func (ch *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(attr)
+		return true
+	})
+	if id := RequestIDFromContext(ctx); id != "" {
+		redacted.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		redacted.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return ch.handler.Handle(ctx, redacted)
+}
+
+func (ch *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{handler: ch.handler.WithAttrs(attrs)}
+}
+
+func (ch *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{handler: ch.handler.WithGroup(name)}
+}
+
+//requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+//WithRequestID returns a copy of `ctx` carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+//RequestIDFromContext returns the request ID in `ctx`, or "" if it has none
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+//redacted replaces values that must not be logged
+const redacted = "[redacted]"
+
+//sensitiveKeys are attribute keys whose values are always redacted
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"refresh-token": true,
+	"password":      true,
+	"auth":          true,
+	"x-api-key":     true,
+}
+
+//sensitiveParams are query string parameters whose values are always redacted
+var sensitiveParams = map[string]bool{
+	"auth":     true,
+	"apikey":   true,
+	"email":    true,
+	"password": true,
+	"token":    true,
+}
+
+var (
+	emailPattern     = regexp.MustCompile(`[A-Za-z0-9._%+\-]+(?:@|%40)[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	authParamPattern = regexp.MustCompile(`(?i)((?:^|[?&])(?:auth|apiKey)=)[^&\s"]*`)
+	bearerPattern    = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=\-]+`)
+)
+
+//Redact removes email addresses, bearer tokens and the values of the
+//`auth` and `apiKey` query parameters from `s`
+func Redact(s string) string {
+	s = emailPattern.ReplaceAllString(s, "[email]")
+	s = authParamPattern.ReplaceAllString(s, "${1}"+redacted)
+	return bearerPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+//RedactQuery redacts a raw query string for logging. Each value is
+//decoded before it's redacted, so URL-encoded emails such as
+//alice%40example.com are caught, and the values of sensitive parameters
+//are removed. The result is for reading, so values stay decoded.
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, value, _ := strings.Cut(param, "=")
+		if decoded, err := url.QueryUnescape(key); err == nil {
+			key = decoded
+		}
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+		if sensitiveParams[strings.ToLower(key)] {
+			value = redacted
+		}
+		params[i] = Redact(key) + "=" + Redact(value)
+	}
+	return strings.Join(params, "&")
+}
+
+//redactAttr redacts the values of sensitive keys, and redacts string values
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+	}
+	return attr
+}
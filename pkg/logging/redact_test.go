@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	cases := map[string]string{
+		"user alice@example.com signed in":                         "user [email] signed in",
+		"GET /v1/news?auth=abc.def&page=2":                         "GET /v1/news?auth=[redacted]&page=2",
+		"GET /v2/top?apiKey=secret":                                "GET /v2/top?apiKey=[redacted]",
+		"Authorization: Bearer abc.def-ghi":                        "Authorization: Bearer [redacted]",
+		"GET /v1/users/available?email=alice%40example.com failed": "GET /v1/users/available?email=[email] failed",
+		"nothing to redact":                                        "nothing to redact",
+	}
+	for in, want := range cases {
+		if got := Redact(in); got != want {
+			t.Errorf("Redact(%q): got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	cases := map[string]string{
+		"":                                "",
+		"page=2&limit=10":                 "page=2&limit=10",
+		"email=alice%40example.com":       "email=[redacted]",
+		"q=alice%40example.com":           "q=[email]",
+		"q=alice%2Bnews%40example.com":    "q=[email]",
+		"q=alice@example.com&page=1":      "q=[email]&page=1",
+		"Email=alice&auth=token&apiKey=k": "Email=[redacted]&auth=[redacted]&apiKey=[redacted]",
+		"q=bad%zzescape":                  "q=bad%zzescape",
+		"flag":                            "flag=",
+	}
+	for in, want := range cases {
+		if got := RedactQuery(in); got != want {
+			t.Errorf("RedactQuery(%q): got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAccessLogRedactsQuery(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buffer, nil)))
+	defer slog.SetDefault(previous)
+
+	handler := NewAccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest("GET", "/v1/users/available?email=alice%40example.com&username=bob", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	logged := buffer.String()
+	if strings.Contains(logged, "alice") {
+		t.Errorf("access log contains the email: %s", logged)
+	}
+	if !strings.Contains(logged, `"query":"email=[redacted]&username=bob"`) {
+		t.Errorf("access log doesn't contain the redacted query: %s", logged)
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/2charm/spectrum-api/pkg/util"
+)
+
+//Namespace prefixes the name of every metric
+const Namespace = "spectrum"
+
+var requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: Namespace,
+	Name:      "http_request_duration_seconds",
+	Help:      "Time taken to serve HTTP requests, by route, method and status.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"route", "method", "status"})
+
+var requestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: Namespace,
+	Name:      "http_requests_in_flight",
+	Help:      "Number of HTTP requests being served.",
+})
+
+var upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: Namespace,
+	Name:      "proxy_upstream_duration_seconds",
+	Help:      "Time taken by services behind the gateway to respond, by upstream, method and status.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"upstream", "method", "code"})
+
+//knownMethods are the methods used as labels, others are counted
+//as OTHER so clients can't create new series at will
+var knownMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true,
+}
+
+//HTTPMetrics records the duration and status of the requests to `handler`.
+//Requests are labeled with the pattern of the `routes` handler they match,
+//so paths with IDs in them don't each become their own series.
+type HTTPMetrics struct {
+	handler http.Handler
+	routes  *http.ServeMux
+}
+
+//NewHTTPMetrics constructs an HTTPMetrics. `handler` is usually `routes`,
+//or middleware wrapping it.
+func NewHTTPMetrics(handler http.Handler, routes *http.ServeMux) *HTTPMetrics {
+	return &HTTPMetrics{handler: handler, routes: routes}
+}
+
+func (hm *HTTPMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestsInFlight.Inc()
+	defer requestsInFlight.Dec()
+	start := time.Now()
+	recorder := util.NewStatusRecorder(w)
+	hm.handler.ServeHTTP(recorder, r)
+
+	_, route := hm.routes.Handler(r)
+	if route == "" {
+		route = "unmatched"
+	}
+	method := r.Method
+	if !knownMethods[method] {
+		method = "OTHER"
+	}
+	requestDuration.WithLabelValues(route, method, strconv.Itoa(recorder.Status)).Observe(time.Since(start).Seconds())
+}
+
+//InstrumentProxy records the time `upstream` takes to respond to
+//requests sent with `transport`
+func InstrumentProxy(upstream string, transport http.RoundTripper) http.RoundTripper {
+	observer := upstreamDuration.MustCurryWith(prometheus.Labels{"upstream": upstream})
+	return promhttp.InstrumentRoundTripperDuration(observer, transport)
+}
+
+//ListenAndServe serves the metrics at /metrics on `addr`. Metrics are
+//served apart from the API so they can be kept off the public network.
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return http.ListenAndServe(addr, mux)
+}
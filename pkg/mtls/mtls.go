@@ -0,0 +1,139 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+//ErrNoPeerCertificate is returned when the peer presents no certificate
+var ErrNoPeerCertificate = errors.New("peer presented no certificate")
+
+//Certificates holds a service's certificate and key, and the CA used to
+//verify its peers. Call Reload to pick up certificates rotated on disk
+//without restarting the service.
+type Certificates struct {
+	mx      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+	//the files the certificates are loaded from
+	certFile string
+	keyFile  string
+	caFile   string
+}
+
+//Load reads the certificate, key and CA files at the given paths
+func Load(certFile string, keyFile string, caFile string) (*Certificates, error) {
+	c := &Certificates{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//Reload re-reads the files if any changed since they were last loaded,
+//and returns true if the certificates were replaced. The previous
+//certificates are kept if a file can't be read or parsed.
+func (c *Certificates) Reload() (bool, error) {
+	modTime := time.Time{}
+	for _, name := range []string{c.certFile, c.keyFile, c.caFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return false, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	c.mx.RLock()
+	unchanged := modTime.Equal(c.modTime)
+	c.mx.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("error loading key pair: %v", err)
+	}
+	caPEM, err := ioutil.ReadFile(c.caFile)
+	if err != nil {
+		return false, fmt.Errorf("error reading CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return false, fmt.Errorf("no certificates found in %s", c.caFile)
+	}
+	c.mx.Lock()
+	c.cert = &cert
+	c.pool = pool
+	c.modTime = modTime
+	c.mx.Unlock()
+	return true, nil
+}
+
+//current returns the certificate and CA pool
+func (c *Certificates) current() (*tls.Certificate, *x509.CertPool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	return c.cert, c.pool
+}
+
+//ServerConfig returns a TLS config for a service that presents the current
+//certificate and requires clients to present a certificate signed by the CA
+func (c *Certificates) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := c.current()
+			return cert, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := c.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+			}, nil
+		},
+	}
+}
+
+//ClientConfig returns a TLS config for a client that presents the current
+//certificate and requires the server's certificate to be signed by the CA
+func (c *Certificates) ClientConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := c.current()
+			return cert, nil
+		},
+		//RootCAs is fixed once the config is in use, so the default
+		//verification is replaced by VerifyConnection, which checks the
+		//server's chain and name against the current CA pool
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return ErrNoPeerCertificate
+			}
+			_, pool := c.current()
+			opts := x509.VerifyOptions{
+				Roots:         pool,
+				DNSName:       state.ServerName,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		},
+	}
+}
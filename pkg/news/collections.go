@@ -0,0 +1,407 @@
+package news
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+const collectionsResourcePath = "/v1/collections/"
+const publicCollectionsResourcePath = "/v1/public/collections/"
+
+//maxSlugLength, maxNameLength and maxDescriptionLength match the collections table
+const maxSlugLength = 64
+const maxNameLength = 128
+const maxDescriptionLength = 1024
+
+//maxCollectionArticles is the most articles a collection may hold
+const maxCollectionArticles = 200
+
+//maxArticleURLLength matches article_url in the collection_articles table
+const maxArticleURLLength = 512
+
+//slugPattern is the form of a collection slug: lowercase words joined by hyphens
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+//slugSeparators matches the runs of characters replaced by a hyphen in a generated slug
+var slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+//newSlug generates a slug from the collection's name, with a random
+//suffix so collections with the same name get different slugs
+func newSlug(name string) (string, error) {
+	base := strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(base) > maxSlugLength-9 {
+		base = strings.Trim(base[:maxSlugLength-9], "-")
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	if base == "" {
+		return hex.EncodeToString(suffix), nil
+	}
+	return base + "-" + hex.EncodeToString(suffix), nil
+}
+
+//canEdit returns true if the user owns or collaborates on the collection, or is an admin
+func canEdit(user *users.User, c *Collection) bool {
+	if user.ID == c.OwnerID || user.HasRole(users.RoleAdmin) {
+		return true
+	}
+	for _, id := range c.Collaborators {
+		if id == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+//validateArticle checks an article a collection will hold. Its URL must be
+//an absolute http or https URL, since clients link to it.
+func validateArticle(article *Article) error {
+	if article == nil || article.URL == "" || article.Title == "" {
+		return fmt.Errorf("every article must have a url and title")
+	}
+	if len(article.URL) > maxArticleURLLength {
+		return fmt.Errorf("article urls must be at most %d characters", maxArticleURLLength)
+	}
+	u, err := url.Parse(article.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("article url %q must be an http or https url", article.URL)
+	}
+	return nil
+}
+
+//validateArticles checks the articles a collection will hold
+func validateArticles(articles []*Article) error {
+	if len(articles) > maxCollectionArticles {
+		return fmt.Errorf("a collection may hold at most %d articles", maxCollectionArticles)
+	}
+	seen := map[string]bool{}
+	for _, article := range articles {
+		if err := validateArticle(article); err != nil {
+			return err
+		}
+		if seen[article.URL] {
+			return fmt.Errorf("article %s appears more than once", article.URL)
+		}
+		seen[article.URL] = true
+	}
+	return nil
+}
+
+//decodeJSON decodes the JSON request body into `value`, rejecting unknown fields
+func decodeJSON(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		apierror.Write(w, r, apierror.UnsupportedMediaType())
+		return false
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(value); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error decoding JSON"))
+		return false
+	}
+	return true
+}
+
+//respondJSON writes `value` as a JSON response with the given status code
+func respondJSON(w http.ResponseWriter, r *http.Request, status int, value interface{}) {
+	buffer, err := json.Marshal(value)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error marshaling JSON", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buffer)
+}
+
+//CollectionsHandler lists the collections the user owns or collaborates
+//on, and creates collections for users allowed to curate them
+func (ctx *HandlerContext) CollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := ctx.getUserFromHeader(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("User not authenticated"))
+		return
+	}
+	switch r.Method {
+	case "GET":
+		collections, err := ctx.collectionStore(r).GetCollectionsForUser(user.ID)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal("can't retrieve collections", err))
+			return
+		}
+		respondJSON(w, r, http.StatusOK, collections)
+	case "POST":
+		if !user.Can(users.PermCurateCollections) {
+			apierror.Write(w, r, apierror.Forbidden("user may not create collections"))
+			return
+		}
+		nc := &NewCollection{}
+		if !decodeJSON(w, r, nc) {
+			return
+		}
+		nc.Name = strings.TrimSpace(nc.Name)
+		if nc.Name == "" || len(nc.Name) > maxNameLength {
+			apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("name must be between 1 and %d characters", maxNameLength)))
+			return
+		}
+		if len(nc.Description) > maxDescriptionLength {
+			apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("description must be at most %d characters", maxDescriptionLength)))
+			return
+		}
+		if nc.Slug == "" {
+			if nc.Slug, err = newSlug(nc.Name); err != nil {
+				apierror.Write(w, r, apierror.Internal("error generating slug", err))
+				return
+			}
+		} else if len(nc.Slug) > maxSlugLength || !slugPattern.MatchString(nc.Slug) {
+			apierror.Write(w, r, apierror.BadRequest("slug must be lowercase letters and digits separated by hyphens"))
+			return
+		}
+		collection, err := ctx.collectionStore(r).InsertCollection(&Collection{
+			Slug:          nc.Slug,
+			Name:          nc.Name,
+			Description:   nc.Description,
+			OwnerID:       user.ID,
+			Public:        nc.Public,
+			Collaborators: []int64{},
+			Articles:      []*Article{},
+		})
+		if err == ErrDuplicateSlug {
+			apierror.Write(w, r, apierror.Conflict(err.Error()).With("field", "slug"))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal("can't create collection", err))
+			return
+		}
+		w.Header().Set("Location", collectionsResourcePath+collection.Slug)
+		respondJSON(w, r, http.StatusCreated, collection)
+	default:
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+	}
+}
+
+//SpecificCollectionHandler handles a collection identified by its slug:
+// GET, PATCH, DELETE /v1/collections/{slug}
+// PUT, POST /v1/collections/{slug}/articles
+// POST /v1/collections/{slug}/collaborators
+// DELETE /v1/collections/{slug}/collaborators/{userID}
+//Only the owner and collaborators may see a private collection or edit
+//any collection, and only the owner may delete it or change collaborators.
+func (ctx *HandlerContext) SpecificCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := ctx.getUserFromHeader(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("User not authenticated"))
+		return
+	}
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, collectionsResourcePath), "/")
+	collection, err := ctx.collectionStore(r).GetCollection(segments[0])
+	if err == ErrCollectionNotFound {
+		apierror.Write(w, r, apierror.NotFound("collection not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("can't retrieve collection", err))
+		return
+	}
+	if !canEdit(user, collection) {
+		//don't reveal that private collections exist
+		if !collection.Public {
+			apierror.Write(w, r, apierror.NotFound("collection not found"))
+			return
+		}
+		if r.Method != "GET" || len(segments) > 1 {
+			apierror.Write(w, r, apierror.Forbidden("user may not edit this collection"))
+			return
+		}
+	}
+
+	switch {
+	case len(segments) == 1:
+		ctx.handleCollection(w, r, user, collection)
+	case len(segments) == 2 && segments[1] == "articles":
+		ctx.handleCollectionArticles(w, r, collection)
+	case segments[1] == "collaborators" && len(segments) <= 3:
+		if user.ID != collection.OwnerID && !user.HasRole(users.RoleAdmin) {
+			apierror.Write(w, r, apierror.Forbidden("only the owner may change collaborators"))
+			return
+		}
+		ctx.handleCollaborators(w, r, collection, segments[2:])
+	default:
+		apierror.Write(w, r, apierror.NotFound("resource not found"))
+	}
+}
+
+//handleCollection gets, updates or deletes the collection
+func (ctx *HandlerContext) handleCollection(w http.ResponseWriter, r *http.Request, user *users.User, collection *Collection) {
+	switch r.Method {
+	case "GET":
+		respondJSON(w, r, http.StatusOK, collection)
+	case "PATCH":
+		updates := &CollectionUpdates{}
+		if !decodeJSON(w, r, updates) {
+			return
+		}
+		if updates.Name != nil {
+			name := strings.TrimSpace(*updates.Name)
+			if name == "" || len(name) > maxNameLength {
+				apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("name must be between 1 and %d characters", maxNameLength)))
+				return
+			}
+			updates.Name = &name
+		}
+		if updates.Description != nil && len(*updates.Description) > maxDescriptionLength {
+			apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("description must be at most %d characters", maxDescriptionLength)))
+			return
+		}
+		if err := ctx.collectionStore(r).UpdateCollection(collection.ID, updates); err != nil {
+			apierror.Write(w, r, apierror.Internal("can't update collection", err))
+			return
+		}
+		ctx.respondCollection(w, r, collection.Slug)
+	case "DELETE":
+		if user.ID != collection.OwnerID && !user.HasRole(users.RoleAdmin) {
+			apierror.Write(w, r, apierror.Forbidden("only the owner may delete a collection"))
+			return
+		}
+		if err := ctx.collectionStore(r).DeleteCollection(collection.ID); err != nil {
+			apierror.Write(w, r, apierror.Internal("can't delete collection", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+	}
+}
+
+//handleCollectionArticles replaces the collection's articles, in the
+//given order, on PUT and appends one article on POST. Appending is done
+//by the store, so articles appended concurrently by collaborators are kept.
+func (ctx *HandlerContext) handleCollectionArticles(w http.ResponseWriter, r *http.Request, collection *Collection) {
+	switch r.Method {
+	case "PUT":
+		var articles []*Article
+		if !decodeJSON(w, r, &articles) {
+			return
+		}
+		if err := validateArticles(articles); err != nil {
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeValidation, err.Error()))
+			return
+		}
+		if err := ctx.collectionStore(r).SetCollectionArticles(collection.ID, articles); err != nil {
+			apierror.Write(w, r, apierror.Internal("can't save articles", err))
+			return
+		}
+	case "POST":
+		article := &Article{}
+		if !decodeJSON(w, r, article) {
+			return
+		}
+		if err := validateArticle(article); err != nil {
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeValidation, err.Error()))
+			return
+		}
+		err := ctx.collectionStore(r).AppendCollectionArticle(collection.ID, article, maxCollectionArticles)
+		if err == ErrCollectionFull {
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeValidation,
+				fmt.Sprintf("a collection may hold at most %d articles", maxCollectionArticles)))
+			return
+		}
+		if err == ErrDuplicateArticle {
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeValidation,
+				fmt.Sprintf("article %s appears more than once", article.URL)))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal("can't save article", err))
+			return
+		}
+	default:
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	ctx.respondCollection(w, r, collection.Slug)
+}
+
+//handleCollaborators adds a collaborator on POST, or removes the
+//collaborator whose user ID is the remaining path segment on DELETE
+func (ctx *HandlerContext) handleCollaborators(w http.ResponseWriter, r *http.Request, collection *Collection, rest []string) {
+	switch {
+	case r.Method == "POST" && len(rest) == 0:
+		nc := &NewCollaborator{}
+		if !decodeJSON(w, r, nc) {
+			return
+		}
+		if nc.UserID <= 0 || nc.UserID == collection.OwnerID {
+			apierror.Write(w, r, apierror.BadRequest("userID must be another user's ID"))
+			return
+		}
+		if err := ctx.collectionStore(r).AddCollaborator(collection.ID, nc.UserID); err != nil {
+			apierror.Write(w, r, apierror.Internal("can't add collaborator", err))
+			return
+		}
+		ctx.respondCollection(w, r, collection.Slug)
+	case r.Method == "DELETE" && len(rest) == 1:
+		userID, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("user ID must be an integer"))
+			return
+		}
+		if err := ctx.collectionStore(r).RemoveCollaborator(collection.ID, userID); err != nil {
+			apierror.Write(w, r, apierror.Internal("can't remove collaborator", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+	}
+}
+
+//respondCollection writes the current state of the collection
+func (ctx *HandlerContext) respondCollection(w http.ResponseWriter, r *http.Request, slug string) {
+	collection, err := ctx.collectionStore(r).GetCollection(slug)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("can't retrieve collection", err))
+		return
+	}
+	respondJSON(w, r, http.StatusOK, collection)
+}
+
+//PublicCollectionHandler returns a public collection by its slug. It needs
+//no session, and private collections are reported as not found.
+func (ctx *HandlerContext) PublicCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	slug := strings.TrimPrefix(r.URL.Path, publicCollectionsResourcePath)
+	collection, err := ctx.collectionStore(r).GetCollection(slug)
+	if err == ErrCollectionNotFound || (err == nil && !collection.Public) {
+		apierror.Write(w, r, apierror.NotFound("collection not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("can't retrieve collection", err))
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	respondJSON(w, r, http.StatusOK, &PublicCollection{
+		Slug:        collection.Slug,
+		Name:        collection.Name,
+		Description: collection.Description,
+		Articles:    collection.Articles,
+		UpdatedAt:   collection.UpdatedAt,
+	})
+}
@@ -0,0 +1,298 @@
+package news
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//ErrCollectionNotFound is returned when a collection can't be found
+var ErrCollectionNotFound = errors.New("collection not found")
+
+//ErrDuplicateSlug is returned when a collection's slug is already taken
+var ErrDuplicateSlug = errors.New("a collection with that slug already exists")
+
+//ErrCollectionFull is returned when an article is appended to a collection
+//already holding the most articles allowed
+var ErrCollectionFull = errors.New("the collection holds the most articles allowed")
+
+//ErrDuplicateArticle is returned when an article is appended to a
+//collection already holding an article with the same URL
+var ErrDuplicateArticle = errors.New("the collection already holds the article")
+
+//errDuplicateEntry is the MySQL error number for a duplicate unique key
+const errDuplicateEntry = 1062
+
+//CollectionStore represents a store for Collections
+type CollectionStore interface {
+	//InsertCollection inserts the collection and returns it with its
+	//DBMS-assigned ID. ErrDuplicateSlug is returned if the slug is taken.
+	InsertCollection(collection *Collection) (*Collection, error)
+
+	//GetCollection returns the collection with the given slug,
+	//including its collaborators and articles in order
+	GetCollection(slug string) (*Collection, error)
+
+	//GetCollectionsForUser returns the collections the given UserID owns
+	//or collaborates on, most recently updated first, without their articles
+	GetCollectionsForUser(userID int64) ([]*Collection, error)
+
+	//UpdateCollection applies the updates to the given collection ID
+	UpdateCollection(id int64, updates *CollectionUpdates) error
+
+	//DeleteCollection deletes the given collection ID with its articles and collaborators
+	DeleteCollection(id int64) error
+
+	//SetCollectionArticles replaces the articles of the given collection ID,
+	//keeping them in the given order
+	SetCollectionArticles(id int64, articles []*Article) error
+
+	//AppendCollectionArticle adds the article to the end of the given
+	//collection ID, atomically, so concurrent appends are all kept.
+	//ErrCollectionFull is returned if the collection already holds `max`
+	//articles, and ErrDuplicateArticle if it already holds the article.
+	AppendCollectionArticle(id int64, article *Article, max int) error
+
+	//AddCollaborator lets the given UserID edit the collection
+	AddCollaborator(id int64, userID int64) error
+
+	//RemoveCollaborator stops the given UserID from editing the collection
+	RemoveCollaborator(id int64, userID int64) error
+}
+
+//collectionColumns lists the columns scanned by scanCollection, in order
+const collectionColumns = "collection_id, slug, name, description, owner_id, is_public, created_at, updated_at"
+
+//scanCollection scans a row of collectionColumns into a Collection
+func scanCollection(row interface{ Scan(...interface{}) error }) (*Collection, error) {
+	c := &Collection{Collaborators: []int64{}, Articles: []*Article{}}
+	if err := row.Scan(&c.ID, &c.Slug, &c.Name, &c.Description, &c.OwnerID, &c.Public,
+		&c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (as *ArticleStore) InsertCollection(collection *Collection) (*Collection, error) {
+	now := time.Now()
+	insq := "insert into collections(slug, name, description, owner_id, is_public, created_at, updated_at) " +
+		"values (?, ?, ?, ?, ?, ?, ?)"
+	res, err := as.Client.Exec(insq, collection.Slug, collection.Name, collection.Description,
+		collection.OwnerID, collection.Public, now, now)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == errDuplicateEntry {
+			return nil, ErrDuplicateSlug
+		}
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	collection.ID = id
+	collection.CreatedAt = now
+	collection.UpdatedAt = now
+	return collection, nil
+}
+
+func (as *ArticleStore) GetCollection(slug string) (*Collection, error) {
+	c, err := scanCollection(as.Client.QueryRow("select "+collectionColumns+" from collections where slug=?", slug))
+	if err == sql.ErrNoRows {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := as.Client.Query("select user_id from collection_collaborators where collection_id=? "+
+		"order by user_id", c.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		c.Collaborators = append(c.Collaborators, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	articleRows, err := as.Client.Query("select article from collection_articles where collection_id=? "+
+		"order by position", c.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer articleRows.Close()
+	for articleRows.Next() {
+		var encoded []byte
+		if err := articleRows.Scan(&encoded); err != nil {
+			return nil, err
+		}
+		article := &Article{}
+		if err := json.Unmarshal(encoded, article); err != nil {
+			return nil, err
+		}
+		c.Articles = append(c.Articles, article)
+	}
+	return c, articleRows.Err()
+}
+
+func (as *ArticleStore) GetCollectionsForUser(userID int64) ([]*Collection, error) {
+	rows, err := as.Client.Query("select "+collectionColumns+" from collections where owner_id=? or "+
+		"collection_id in (select collection_id from collection_collaborators where user_id=?) "+
+		"order by updated_at desc", userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	collections := []*Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+func (as *ArticleStore) UpdateCollection(id int64, updates *CollectionUpdates) error {
+	c, err := scanCollection(as.Client.QueryRow("select "+collectionColumns+" from collections where collection_id=?", id))
+	if err == sql.ErrNoRows {
+		return ErrCollectionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if updates.Name != nil {
+		c.Name = *updates.Name
+	}
+	if updates.Description != nil {
+		c.Description = *updates.Description
+	}
+	if updates.Public != nil {
+		c.Public = *updates.Public
+	}
+	_, err = as.Client.Exec("update collections set name=?, description=?, is_public=?, updated_at=? "+
+		"where collection_id=?", c.Name, c.Description, c.Public, time.Now(), id)
+	return err
+}
+
+func (as *ArticleStore) DeleteCollection(id int64) error {
+	tx, err := as.Client.Begin()
+	if err != nil {
+		return err
+	}
+	for _, delq := range []string{
+		"delete from collection_articles where collection_id=?",
+		"delete from collection_collaborators where collection_id=?",
+		"delete from collections where collection_id=?",
+	} {
+		if _, err := tx.Exec(delq, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+//lockCollection locks the collection's row until `tx` ends, so changes to
+//its articles are made one at a time
+func lockCollection(tx *sql.Tx, id int64) error {
+	var locked int64
+	err := tx.QueryRow("select collection_id from collections where collection_id=? for update", id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrCollectionNotFound
+	}
+	return err
+}
+
+func (as *ArticleStore) SetCollectionArticles(id int64, articles []*Article) error {
+	tx, err := as.Client.Begin()
+	if err != nil {
+		return err
+	}
+	if err := lockCollection(tx, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec("delete from collection_articles where collection_id=?", id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	insq := "insert into collection_articles(collection_id, position, article_url, article) values (?, ?, ?, ?)"
+	for position, article := range articles {
+		encoded, err := json.Marshal(article)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(insq, id, position, article.URL, encoded); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec("update collections set updated_at=? where collection_id=?", time.Now(), id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (as *ArticleStore) AppendCollectionArticle(id int64, article *Article, max int) error {
+	encoded, err := json.Marshal(article)
+	if err != nil {
+		return err
+	}
+	tx, err := as.Client.Begin()
+	if err != nil {
+		return err
+	}
+	if err := lockCollection(tx, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	var count, duplicates, next int
+	err = tx.QueryRow("select count(*), coalesce(sum(article_url=?), 0), coalesce(max(position)+1, 0) "+
+		"from collection_articles where collection_id=?", article.URL, id).Scan(&count, &duplicates, &next)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count >= max {
+		tx.Rollback()
+		return ErrCollectionFull
+	}
+	if duplicates > 0 {
+		tx.Rollback()
+		return ErrDuplicateArticle
+	}
+	insq := "insert into collection_articles(collection_id, position, article_url, article) values (?, ?, ?, ?)"
+	if _, err := tx.Exec(insq, id, next, article.URL, encoded); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec("update collections set updated_at=? where collection_id=?", time.Now(), id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (as *ArticleStore) AddCollaborator(id int64, userID int64) error {
+	_, err := as.Client.Exec("insert ignore into collection_collaborators(collection_id, user_id) values (?, ?)",
+		id, userID)
+	return err
+}
+
+func (as *ArticleStore) RemoveCollaborator(id int64, userID int64) error {
+	_, err := as.Client.Exec("delete from collection_collaborators where collection_id=? and user_id=?", id, userID)
+	return err
+}
@@ -1,19 +1,26 @@
 package news
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
-	cache "github.com/patrickmn/go-cache"
-
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/identity"
+	"github.com/2charm/spectrum-api/pkg/tracing"
+	"github.com/2charm/spectrum-api/pkg/upstream"
 	"github.com/2charm/spectrum-api/pkg/users"
-	prose "gopkg.in/jdkato/prose.v2"
+	"github.com/jdkato/prose/v2"
+	cache "github.com/patrickmn/go-cache"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const baseURL = "https://newsapi.org/v2/"
@@ -23,75 +30,79 @@ var categories = []string{"sports", "health", "business", "entertainment", "scie
 
 //HandlerContext provides context for news handler package
 type HandlerContext struct {
-	APIKey       string
-	ArticleStore Store
-	ArticleCache *cache.Cache
+	APIKey          string
+	ArticleStore    Store
+	CollectionStore CollectionStore
+	ArticleCache    *cache.Cache
+	//TopArticlesTTL and RelatedArticlesTTL are how long cached articles
+	//are fresh. StaleFor is how long they're kept after they go stale, to
+	//be served instead when the NewsAPI quota is exhausted.
+	TopArticlesTTL     time.Duration
+	RelatedArticlesTTL time.Duration
+	StaleFor           time.Duration
+	//Quota counts and limits the calls made to NewsAPI
+	Quota *Quota
+	//NewsAPI makes the calls to NewsAPI
+	NewsAPI *upstream.Client
+	//IdentityVerifier checks the user assertions signed by the gateway
+	IdentityVerifier *identity.Verifier
 }
 
-func getUserFromHeader(r *http.Request) (*users.User, error) {
-	val := r.Header.Get("X-User")
-	if len(val) == 0 {
-		log.Print("No X-User in Header")
-		return nil, fmt.Errorf("No X-User in Header")
-	}
-	user := users.User{}
-	err := json.Unmarshal([]byte(val), &user)
+//getUserFromHeader returns the user asserted by the gateway in the X-User
+//header. Unsigned, forged and expired assertions are rejected.
+func (ctx *HandlerContext) getUserFromHeader(r *http.Request) (*users.User, error) {
+	user := &users.User{}
+	err := ctx.IdentityVerifier.Verify(r.Header.Get(identity.HeaderUser), r.Header.Get(apierror.HeaderRequestID), user)
 	if err != nil {
-		log.Printf("Error unmarshalling X-User")
-		return nil, fmt.Errorf("Error unmarshalling X-User")
+		slog.WarnContext(r.Context(), "rejected user assertion", "error", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+//articleStore returns the ArticleStore, with its queries traced as part of the request
+func (ctx *HandlerContext) articleStore(r *http.Request) Store {
+	if as, ok := ctx.ArticleStore.(*ArticleStore); ok && as != nil {
+		return as.WithContext(r.Context())
 	}
-	return &user, nil
+	return ctx.ArticleStore
+}
+
+//collectionStore returns the CollectionStore, with its queries traced as part of the request
+func (ctx *HandlerContext) collectionStore(r *http.Request) CollectionStore {
+	if as, ok := ctx.CollectionStore.(*ArticleStore); ok && as != nil {
+		return as.WithContext(r.Context())
+	}
+	return ctx.CollectionStore
 }
 
 //NewsHandler handles requests for the articles needed by client
 func (ctx *HandlerContext) NewsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
-		http.Error(w, "Invalid http method.", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed())
 		return
 	}
-	log.Print("GET /v1/news")
-
 	var response map[string]interface{}
-	//cachedArticles, expire, exists := ctx.ArticleCache.GetWithExpiration(cacheKey)
-	if cachedArticles, exists := ctx.ArticleCache.Get(cacheKey); exists {
-		response = cachedArticles.(map[string]interface{})
+	cached, fresh, exists := ctx.getCached(cacheKey)
+	if exists && fresh {
+		response = cached.(map[string]interface{})
 	} else {
-		response = map[string]interface{}{}
-		for _, category := range categories {
-			articles, err := getArticlesByCategory(category, ctx.APIKey)
-			if err != nil {
-				log.Printf("API call went wrong for %s category: %v", category, err.Error())
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			articles = checkSpectrumEnabled(articles)
-			response[category] = articles
-		}
-		articles, err := callNewsAPI("top-headlines", "country=us&category=general&pageSize=10&apiKey="+ctx.APIKey)
-		if err != nil {
-			log.Printf("API call went wrong for headlines: %v", err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		articles = checkSpectrumEnabled(articles)
-		response["headline"] = articles
-
-		articles, err = getArticlesByCategory("general", ctx.APIKey)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		articles, err := ctx.getTopArticles(r.Context())
+		if err != nil && exists && canServeStale(err) {
+			slog.WarnContext(r.Context(), "serving stale articles", "error", err)
+			ctx.Quota.ServedStale()
+			response = cached.(map[string]interface{})
+		} else if err != nil {
+			ctx.writeNewsAPIError(w, r, "error retrieving articles from NewsAPI", err)
 			return
-		}
-		articles = checkSpectrumEnabled(articles)
-		response["us"] = articles
-		err = ctx.ArticleCache.Add(cacheKey, response, time.Hour*3)
-		if err != nil {
-			log.Print("Error inserting articles to cache")
+		} else {
+			response = articles
+			ctx.setCached(cacheKey, response, ctx.TopArticlesTTL)
 		}
 	}
 	buffer, err := json.Marshal(response)
 	if err != nil {
-		log.Print("Marshal error")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("error marshaling JSON", err))
 		return
 	}
 	w.Write(buffer)
@@ -100,83 +111,203 @@ func (ctx *HandlerContext) NewsHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+//getTopArticles fetches the top articles of every category and the headlines
+func (ctx *HandlerContext) getTopArticles(reqCtx context.Context) (map[string]interface{}, error) {
+	response := map[string]interface{}{}
+	for _, category := range categories {
+		articles, err := ctx.getArticlesByCategory(reqCtx, category)
+		if err != nil {
+			slog.WarnContext(reqCtx, "error retrieving category", "category", category, "error", err)
+			return nil, err
+		}
+		articles = checkSpectrumEnabled(reqCtx, articles)
+		response[category] = articles
+	}
+	articles, err := ctx.callNewsAPI(reqCtx, "top-headlines", "country=us&category=general&pageSize=10", PriorityRefresh)
+	if err != nil {
+		slog.WarnContext(reqCtx, "error retrieving headlines", "error", err)
+		return nil, err
+	}
+	articles = checkSpectrumEnabled(reqCtx, articles)
+	response["headline"] = articles
+
+	articles, err = ctx.getArticlesByCategory(reqCtx, "general")
+	if err != nil {
+		return nil, err
+	}
+	articles = checkSpectrumEnabled(reqCtx, articles)
+	response["us"] = articles
+	return response, nil
+}
+
 //MetricsHandler handles requests for metrics by users
 func (ctx *HandlerContext) MetricsHandler(w http.ResponseWriter, r *http.Request) {
-	user, err := getUserFromHeader(r)
+	user, err := ctx.getUserFromHeader(r)
 	if err != nil {
-		log.Print("User not authenticated")
-		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthenticated("User not authenticated"))
 		return
 	}
 	if r.Method == "POST" {
-		log.Print("POST /v1/metrics")
 		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			log.Printf("Error reading body: %v", err)
-			http.Error(w, "can't read body", http.StatusBadRequest)
+			apierror.Write(w, r, apierror.BadRequest("can't read body"))
 			return
 		}
 		metric := NewMetric{}
 		err = json.Unmarshal(body, &metric)
 		if err != nil {
-			log.Printf("Error unmarshalling json metric: %v", err)
-			http.Error(w, "Error unmarshalling json", http.StatusBadRequest)
+			apierror.Write(w, r, apierror.BadRequest("Error unmarshalling json"))
 			return
 		}
 
-		err = ctx.ArticleStore.InsertArticle(metric, user.ID)
+		err = ctx.articleStore(r).InsertArticle(metric, user.ID)
 		if err != nil {
-			log.Printf("Error inserting article: %v", err)
-			http.Error(w, "can't insert article", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal("can't insert article", err))
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
 	} else if r.Method == "GET" {
-		metrics, err := ctx.ArticleStore.GetByUserID(user.ID)
+		metrics, err := ctx.articleStore(r).GetByUserID(user.ID)
 		if err != nil {
-			log.Printf("Error retrieving metrics: %v", err)
-			http.Error(w, "can't retrieve metrics", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal("can't retrieve metrics", err))
 			return
 		}
 		buffer, err := json.Marshal(metrics)
 		if err != nil {
-			log.Print("Marshal error")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal("error marshaling JSON", err))
 			return
 		}
 		w.Write(buffer)
 		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Content-Type", "application/json")
 	} else {
-		http.Error(w, "Invalid http method.", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed())
 		return
 	}
 }
 
+//HistoryHandler handles requests for a user's reading history
+func (ctx *HandlerContext) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	user, err := ctx.getUserFromHeader(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("User not authenticated"))
+		return
+	}
+	history, err := ctx.articleStore(r).GetHistory(user.ID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("can't retrieve history", err))
+		return
+	}
+	buffer, err := json.Marshal(history)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error marshaling JSON", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buffer)
+}
+
+//maxFeedUsers and maxFeedLimit bound the size of a feed request
+const maxFeedUsers = 500
+const maxFeedLimit = 200
+
+//FeedHandler handles requests for the articles recently read by a set of
+//users. The gateway only passes the IDs of users who share their activity,
+//each as `id:since`, where since is the Unix time they started sharing.
+func (ctx *HandlerContext) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	if _, err := ctx.getUserFromHeader(r); err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("User not authenticated"))
+		return
+	}
+	feedUsers := []*FeedUser{}
+	for _, field := range strings.Split(r.URL.Query().Get("userIDs"), ",") {
+		if field == "" {
+			continue
+		}
+		feedUser, err := parseFeedUser(field)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("userIDs must be a comma separated list of id:since pairs of integers"))
+			return
+		}
+		feedUsers = append(feedUsers, feedUser)
+	}
+	if len(feedUsers) > maxFeedUsers {
+		apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("at most %d userIDs are allowed", maxFeedUsers)))
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > maxFeedLimit {
+		apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("limit must be between 1 and %d", maxFeedLimit)))
+		return
+	}
+	feed, err := ctx.articleStore(r).GetFeed(feedUsers, limit)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("can't retrieve feed", err))
+		return
+	}
+	buffer, err := json.Marshal(feed)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error marshaling JSON", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buffer)
+}
+
+//parseFeedUser parses an `id:since` pair of FeedHandler's userIDs
+func parseFeedUser(field string) (*FeedUser, error) {
+	parts := strings.Split(field, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid feed user %q", field)
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	since, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &FeedUser{UserID: id, Since: time.Unix(since, 0)}, nil
+}
+
 //SpectrumHandler handles requests for related articles needed by client
 func (ctx *HandlerContext) SpectrumHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
-		http.Error(w, "Invalid http method.", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed())
 		return
 	}
 	title := path.Base(r.URL.String())
 
 	var response []Article
-	var err error
-	if cachedArticles, exists := ctx.ArticleCache.Get(title); exists {
-		response = cachedArticles.([]Article)
+	cached, fresh, exists := ctx.getCached(title)
+	if exists && fresh {
+		response = cached.([]Article)
 	} else {
-		response, err = getRelatedArticles(title, ctx.APIKey)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error retrieving related articles:%s", err.Error()), http.StatusInternalServerError)
+		articles, err := ctx.getRelatedArticles(r.Context(), title)
+		if err != nil && exists && canServeStale(err) {
+			ctx.Quota.ServedStale()
+			response = cached.([]Article)
+		} else if err != nil {
+			ctx.writeNewsAPIError(w, r, "error retrieving related articles", err)
 			return
+		} else {
+			response = articles
+			ctx.setCached(title, response, ctx.RelatedArticlesTTL)
 		}
-		ctx.ArticleCache.Add(title, response, time.Hour*15)
 	}
 
 	buffer, err := json.Marshal(response)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("error marshaling JSON", err))
 		return
 	}
 	w.Write(buffer)
@@ -184,15 +315,108 @@ func (ctx *HandlerContext) SpectrumHandler(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Content-Type", "application/json")
 }
 
-func getArticlesByCategory(category string, key string) ([]Article, error) {
-	return callNewsAPI("top-headlines", "country=us&pageSize=10&category="+category+"&apiKey="+key)
+//QuotaHandler returns today's NewsAPI usage to admins
+func (ctx *HandlerContext) QuotaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	user, err := ctx.getUserFromHeader(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthenticated("User not authenticated"))
+		return
+	}
+	if !user.HasRole(users.RoleAdmin) {
+		apierror.Write(w, r, apierror.Forbidden("user is not authorized"))
+		return
+	}
+	buffer, err := json.Marshal(ctx.Quota.Usage())
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("error marshaling JSON", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buffer)
+}
+
+//canServeStale returns true if the NewsAPI call failed because NewsAPI
+//can't be called for now, rather than because the request was wrong,
+//so stale cached articles should be served instead
+func canServeStale(err error) bool {
+	return errors.Is(err, ErrQuotaExhausted) || errors.Is(err, ErrNewsAPIRateLimited) ||
+		errors.Is(err, upstream.ErrCircuitOpen)
 }
 
-func callNewsAPI(endpoint string, query string) ([]Article, error) {
-	reqURL := baseURL + endpoint + "?" + query
-	resp, err := http.Get(reqURL)
+//writeNewsAPIError responds with the error of a failed NewsAPI call. Calls
+//that can be made again later get a 503 with Retry-After, the rest a 502.
+func (ctx *HandlerContext) writeNewsAPIError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	openErr := &upstream.OpenError{}
+	switch {
+	case errors.Is(err, ErrQuotaExhausted):
+		w.Header().Set("Retry-After", strconv.Itoa(int(ctx.Quota.RetryAfter().Seconds())+1))
+		apierror.Write(w, r, apierror.Unavailable("NewsAPI quota exhausted, try again later", err))
+	case errors.As(err, &openErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(openErr.RetryAfter.Seconds())+1))
+		apierror.Write(w, r, apierror.Unavailable("NewsAPI is unavailable, try again later", err))
+	case errors.Is(err, ErrNewsAPIRateLimited):
+		apierror.Write(w, r, apierror.Unavailable("NewsAPI is rate limiting requests, try again later", err))
+	default:
+		apierror.Write(w, r, apierror.Upstream(message, err))
+	}
+}
+
+//getCached returns the cached value for `key`, whether it's still
+//fresh, and whether it exists at all
+func (ctx *HandlerContext) getCached(key string) (interface{}, bool, bool) {
+	cached, exists := ctx.ArticleCache.Get(key)
+	if !exists {
+		articleCacheLookups.WithLabelValues("miss").Inc()
+		return nil, false, false
+	}
+	entry := cached.(*cacheEntry)
+	fresh := time.Now().Before(entry.freshUntil)
+	if fresh {
+		articleCacheLookups.WithLabelValues("hit").Inc()
+	} else {
+		articleCacheLookups.WithLabelValues("stale").Inc()
+	}
+	return entry.value, fresh, true
+}
+
+//setCached caches the value for `key`. It's fresh for `freshFor`, then
+//kept stale for StaleFor in case the NewsAPI quota runs out.
+func (ctx *HandlerContext) setCached(key string, value interface{}, freshFor time.Duration) {
+	ctx.ArticleCache.Set(key, &cacheEntry{value: value, freshUntil: time.Now().Add(freshFor)}, freshFor+ctx.StaleFor)
+}
+
+func (ctx *HandlerContext) getArticlesByCategory(reqCtx context.Context, category string) ([]Article, error) {
+	return ctx.callNewsAPI(reqCtx, "top-headlines", "country=us&pageSize=10&category="+category, PriorityRefresh)
+}
+
+//callNewsAPI calls the NewsAPI endpoint if the quota allows a call of
+//the given priority, and returns ErrQuotaExhausted otherwise
+func (ctx *HandlerContext) callNewsAPI(reqCtx context.Context, endpoint string, query string, priority Priority) ([]Article, error) {
+	spanCtx, span := tracing.Start(reqCtx, "callNewsAPI",
+		attribute.String("newsapi.endpoint", endpoint),
+		attribute.Bool("newsapi.on_demand", priority == PriorityOnDemand))
+	articles, err := ctx.doNewsAPICall(spanCtx, endpoint, query, priority)
+	result := newsAPIResult(err)
+	span.SetAttributes(attribute.String("newsapi.result", result), attribute.Int("newsapi.articles", len(articles)))
+	tracing.End(span, err)
+	newsAPICalls.WithLabelValues(endpoint, result).Inc()
+	return articles, err
+}
+
+//doNewsAPICall makes a call for callNewsAPI. Every attempt, retries
+//included, is taken from the quota, as NewsAPI counts each one.
+func (ctx *HandlerContext) doNewsAPICall(reqCtx context.Context, endpoint string, query string, priority Priority) ([]Article, error) {
+	header := http.Header{"X-Api-Key": {ctx.APIKey}}
+	take := func() error {
+		return ctx.Quota.Take(endpoint, priority)
+	}
+	resp, err := ctx.NewsAPI.Get(reqCtx, endpoint, baseURL+endpoint+"?"+query, header, take)
 	if err != nil {
-		return nil, fmt.Errorf("Error calling NewsAPI everything: %v", err)
+		return nil, fmt.Errorf("Error calling NewsAPI %s: %w", endpoint, err)
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
@@ -202,14 +426,26 @@ func callNewsAPI(endpoint string, query string) ([]Article, error) {
 	headlines := &Headlines{}
 
 	err = json.Unmarshal(body, headlines)
-	if err != nil {
-		log.Print(string(body))
+	if err != nil && resp.StatusCode < 400 {
+		slog.WarnContext(reqCtx, "unexpected NewsAPI response", "endpoint", endpoint, "status", resp.StatusCode, "bytes", len(body))
 		return nil, fmt.Errorf("Error unmarshalling bytes: %v", err)
 	}
+	if headlines.Status == "error" || resp.StatusCode >= 400 {
+		return nil, &NewsAPIError{StatusCode: resp.StatusCode, Code: headlines.Code, Message: headlines.Message}
+	}
 	return headlines.Articles, nil
 }
 
-func getKeywords(title string) (string, error) {
+func getKeywords(reqCtx context.Context, title string) (string, error) {
+	_, span := tracing.Start(reqCtx, "getKeywords")
+	keywords, err := extractKeywords(title)
+	span.SetAttributes(attribute.Int("keywords.title_length", len(title)))
+	tracing.End(span, err)
+	return keywords, err
+}
+
+//extractKeywords returns the named entities in the title, joined by %20
+func extractKeywords(title string) (string, error) {
 	title = strings.Replace(title, "%20", " ", -1)
 	title = strings.Replace(title, "'", " ", -1)
 	doc, err := prose.NewDocument(title)
@@ -226,20 +462,20 @@ func getKeywords(title string) (string, error) {
 	return strings.Join(keywords, "%20"), nil
 }
 
-func getRelatedArticles(title string, key string) ([]Article, error) {
-	keywords, err := getKeywords(title)
-	log.Printf("Related keywords to %s: %s", title, keywords)
+func (ctx *HandlerContext) getRelatedArticles(reqCtx context.Context, title string) ([]Article, error) {
+	keywords, err := getKeywords(reqCtx, title)
 	if err != nil {
 		return nil, err
 	}
-	return callNewsAPI("everything", "sortBy=relevancy&language=en&pageSize=10&q="+keywords+"&apiKey="+key)
+	slog.DebugContext(reqCtx, "extracted keywords", "keywords", keywords)
+	return ctx.callNewsAPI(reqCtx, "everything", "sortBy=relevancy&language=en&pageSize=10&q="+keywords, PriorityOnDemand)
 }
 
-func checkSpectrumEnabled(articles []Article) []Article {
+func checkSpectrumEnabled(reqCtx context.Context, articles []Article) []Article {
 	for i, article := range articles {
-		keywords, err := getKeywords(article.Title[:strings.LastIndex(article.Title, "-")])
+		keywords, err := getKeywords(reqCtx, article.Title[:strings.LastIndex(article.Title, "-")])
 		if err == nil && strings.Contains(keywords, "%20") {
-			log.Print(keywords, " enabled")
+			slog.DebugContext(reqCtx, "spectrum enabled", "keywords", keywords)
 			articles[i].SpectrumEnabled = true
 		}
 	}
@@ -0,0 +1,130 @@
+package news
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/apierror"
+	"github.com/2charm/spectrum-api/pkg/identity"
+	"github.com/2charm/spectrum-api/pkg/users"
+)
+
+var testIdentityKey = []byte("0123456789abcdef0123456789abcdef")
+
+//fakeStore is a Store and CollectionStore holding a fixed reading history
+type fakeStore struct {
+	CollectionStore
+	history []*ReadEvent
+	userID  int64
+}
+
+func (fs *fakeStore) GetByUserID(userID int64) (*Metrics, error) {
+	return &Metrics{UserID: userID}, nil
+}
+
+func (fs *fakeStore) InsertArticle(metric NewMetric, userID int64) error {
+	return nil
+}
+
+func (fs *fakeStore) GetFeed(feedUsers []*FeedUser, limit int) ([]*FeedEvent, error) {
+	return []*FeedEvent{}, nil
+}
+
+func (fs *fakeStore) getCategoryID(category string) (int, error) {
+	return 0, nil
+}
+
+func (fs *fakeStore) getCategoryByID(categoryID int) (string, error) {
+	return "", nil
+}
+
+func (fs *fakeStore) GetHistory(userID int64) ([]*ReadEvent, error) {
+	fs.userID = userID
+	return fs.history, nil
+}
+
+func (fs *fakeStore) GetCollectionsForUser(userID int64) ([]*Collection, error) {
+	fs.userID = userID
+	return []*Collection{}, nil
+}
+
+//newTestContext returns a HandlerContext using `store` for articles and collections
+func newTestContext(store Store, collections CollectionStore) *HandlerContext {
+	return &HandlerContext{
+		ArticleStore:     store,
+		CollectionStore:  collections,
+		IdentityVerifier: identity.NewHMACVerifier(testIdentityKey, time.Second),
+	}
+}
+
+//newUserRequest returns a request carrying the gateway's assertion of the user
+func newUserRequest(t *testing.T, method string, target string, user *users.User) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	assertion, err := identity.NewHMACSigner(testIdentityKey, time.Minute).Sign(user, "req-1")
+	if err != nil {
+		t.Fatalf("Sign: unexpected error %v", err)
+	}
+	r.Header.Set(apierror.HeaderRequestID, "req-1")
+	r.Header.Set(identity.HeaderUser, assertion)
+	return r
+}
+
+func TestHistoryHandlerUsesStore(t *testing.T) {
+	readOn := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := &fakeStore{history: []*ReadEvent{{Category: "science", Source: "BBC", ReadOn: readOn}}}
+	ctx := newTestContext(store, store)
+	w := httptest.NewRecorder()
+	ctx.HistoryHandler(w, newUserRequest(t, "GET", "/v1/metrics/history", &users.User{ID: 7}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("HistoryHandler: got status %d, want 200: %s", w.Code, w.Body)
+	}
+	history := []*ReadEvent{}
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("decoding history: unexpected error %v", err)
+	}
+	if store.userID != 7 || len(history) != 1 || history[0].Category != "science" {
+		t.Errorf("HistoryHandler: got history %v for user %d", history, store.userID)
+	}
+}
+
+func TestCollectionsHandlerUsesStore(t *testing.T) {
+	store := &fakeStore{}
+	ctx := newTestContext(store, store)
+	w := httptest.NewRecorder()
+	ctx.CollectionsHandler(w, newUserRequest(t, "GET", "/v1/collections", &users.User{ID: 9}))
+	if w.Code != http.StatusOK || store.userID != 9 {
+		t.Errorf("CollectionsHandler: got status %d for user %d, want 200 for user 9", w.Code, store.userID)
+	}
+}
+
+//TestHandlersUseArticleStoreWithContext goes through the helpers that give
+//an *ArticleStore the request's context. Its database can't be reached, so
+//each handler must report the error rather than crash.
+func TestHandlersUseArticleStoreWithContext(t *testing.T) {
+	db, err := sql.Open("mysql", "user:password@tcp(127.0.0.1:1)/news?timeout=1s")
+	if err != nil {
+		t.Fatalf("sql.Open: unexpected error %v", err)
+	}
+	defer db.Close()
+	as := NewArticleStore(db)
+	ctx := newTestContext(as, as)
+	handlers := map[string]struct {
+		handler http.HandlerFunc
+		target  string
+	}{
+		"metrics":     {ctx.MetricsHandler, "/v1/metrics"},
+		"history":     {ctx.HistoryHandler, "/v1/metrics/history"},
+		"collections": {ctx.CollectionsHandler, "/v1/collections"},
+	}
+	for name, h := range handlers {
+		w := httptest.NewRecorder()
+		h.handler(w, newUserRequest(t, "GET", h.target, &users.User{ID: 7}))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: got status %d, want 500", name, w.Code)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package news
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/2charm/spectrum-api/pkg/upstream"
+)
+
+//newsAPIHealth is the state of NewsAPI included in readiness reports
+type newsAPIHealth struct {
+	Breakers       map[string]string `json:"breakers"`
+	QuotaRemaining int               `json:"quotaRemaining"`
+}
+
+//CheckNewsAPI checks that NewsAPI can be reached, that no endpoint's
+//breaker is open and that the day's quota isn't used up. The request
+//is sent without the API key, so it doesn't count against the quota.
+func (ctx *HandlerContext) CheckNewsAPI(c context.Context) (interface{}, error) {
+	details := &newsAPIHealth{
+		Breakers:       ctx.NewsAPI.Breakers.States(),
+		QuotaRemaining: ctx.Quota.Usage().Remaining,
+	}
+	for endpoint, state := range details.Breakers {
+		if state == upstream.StateOpen.String() {
+			return details, fmt.Errorf("circuit breaker for %s is open", endpoint)
+		}
+	}
+	if details.QuotaRemaining == 0 {
+		return details, ErrQuotaExhausted
+	}
+	req, err := http.NewRequest("HEAD", baseURL, nil)
+	if err != nil {
+		return details, err
+	}
+	resp, err := ctx.NewsAPI.HTTPClient.Do(req.WithContext(c))
+	if err != nil {
+		return details, fmt.Errorf("NewsAPI is unreachable: %v", err)
+	}
+	resp.Body.Close()
+	return details, nil
+}
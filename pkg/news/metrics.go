@@ -0,0 +1,48 @@
+package news
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/2charm/spectrum-api/pkg/upstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var articleCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spectrum",
+	Name:      "article_cache_lookups_total",
+	Help:      "ArticleCache lookups, by whether a fresh, stale or no entry was found.",
+}, []string{"result"})
+
+var newsAPICalls = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spectrum",
+	Name:      "newsapi_calls_total",
+	Help:      "Calls to NewsAPI, by endpoint and result. Results other than ok are errors.",
+}, []string{"endpoint", "result"})
+
+//knownCodes are the NewsAPI error codes used as result labels
+var knownCodes = map[string]bool{
+	CodeAPIKeyDisabled: true, CodeAPIKeyExhausted: true, CodeAPIKeyInvalid: true, CodeAPIKeyMissing: true,
+	CodeParameterInvalid: true, CodeParametersMissing: true, CodeRateLimited: true,
+	CodeSourcesTooMany: true, CodeSourceDoesNotExist: true, CodeUnexpectedError: true,
+}
+
+//newsAPIResult returns the result label for the outcome of a NewsAPI call
+func newsAPIResult(err error) string {
+	apiErr := &NewsAPIError{}
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrQuotaExhausted):
+		return "quota_exhausted"
+	case errors.Is(err, upstream.ErrCircuitOpen):
+		return "circuit_open"
+	case errors.As(err, &apiErr) && knownCodes[apiErr.Code]:
+		return apiErr.Code
+	case errors.As(err, &apiErr):
+		return "http_" + strconv.Itoa(apiErr.StatusCode)
+	default:
+		return "error"
+	}
+}
@@ -1,9 +1,14 @@
 package news
 
+import "time"
+
 type Headlines struct {
 	Status       string    `json:"status"`
 	TotalResults int       `json:"totalResults"`
 	Articles     []Article `json:"articles"`
+	//Code and Message are set when Status is "error"
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
 }
 
 type Article struct {
@@ -18,6 +23,12 @@ type Article struct {
 	SpectrumEnabled bool   `json:"spectrumEnabled"`
 }
 
+//cacheEntry is a cached NewsAPI response and the time it goes stale
+type cacheEntry struct {
+	value      interface{}
+	freshUntil time.Time
+}
+
 type source struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
@@ -29,6 +40,73 @@ type Metrics struct {
 	SourceToNumArticles   map[string]int `json:"sourceToNumArticles"`
 }
 
+//ReadEvent is one article read by a user
+type ReadEvent struct {
+	Category string    `json:"category"`
+	Source   string    `json:"source"`
+	ReadOn   time.Time `json:"readOn"`
+}
+
+//FeedUser is a user whose reading is shown in a feed. Only articles read
+//since they started sharing their activity are shown.
+type FeedUser struct {
+	UserID int64
+	Since  time.Time
+}
+
+//FeedEvent is one article read by a user, as shown in a follower's feed
+type FeedEvent struct {
+	UserID   int64     `json:"userID"`
+	Category string    `json:"category"`
+	Source   string    `json:"source"`
+	ReadOn   time.Time `json:"readOn"`
+}
+
+//Collection is a named, ordered list of articles curated by its owner and collaborators
+type Collection struct {
+	ID            int64      `json:"id"`
+	Slug          string     `json:"slug"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	OwnerID       int64      `json:"ownerID"`
+	Public        bool       `json:"public"`
+	Collaborators []int64    `json:"collaborators"`
+	Articles      []*Article `json:"articles"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+//PublicCollection is the read-only view of a public collection
+type PublicCollection struct {
+	Slug        string     `json:"slug"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Articles    []*Article `json:"articles"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+//NewCollection is a request to create a collection. A slug is
+//generated from the name if one isn't given.
+type NewCollection struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+}
+
+//CollectionUpdates are the allowed updates to a collection.
+//Fields left out of the request are unchanged.
+type CollectionUpdates struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Public      *bool   `json:"public"`
+}
+
+//NewCollaborator is a request to let a user edit a collection
+type NewCollaborator struct {
+	UserID int64 `json:"userID"`
+}
+
 type NewMetric struct {
 	Category string `json:"category"`
 	Source   string `json:"source"`
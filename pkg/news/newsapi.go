@@ -0,0 +1,84 @@
+package news
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+//Error codes returned by NewsAPI in the "code" of an error response
+const (
+	CodeAPIKeyDisabled     = "apiKeyDisabled"
+	CodeAPIKeyExhausted    = "apiKeyExhausted"
+	CodeAPIKeyInvalid      = "apiKeyInvalid"
+	CodeAPIKeyMissing      = "apiKeyMissing"
+	CodeParameterInvalid   = "parameterInvalid"
+	CodeParametersMissing  = "parametersMissing"
+	CodeRateLimited        = "rateLimited"
+	CodeSourcesTooMany     = "sourcesTooMany"
+	CodeSourceDoesNotExist = "sourceDoesNotExist"
+	CodeUnexpectedError    = "unexpectedError"
+)
+
+//ErrNewsAPIKey matches NewsAPI errors caused by a missing, invalid or disabled API key
+var ErrNewsAPIKey = errors.New("NewsAPI rejected the API key")
+
+//ErrNewsAPIRateLimited matches NewsAPI errors for exceeding its rate limit or plan
+var ErrNewsAPIRateLimited = errors.New("NewsAPI rate limit exceeded")
+
+//ErrNewsAPIRequest matches NewsAPI errors caused by an invalid request
+var ErrNewsAPIRequest = errors.New("NewsAPI rejected the request")
+
+//ErrNewsAPIUnavailable matches NewsAPI's own failures
+var ErrNewsAPIUnavailable = errors.New("NewsAPI failed")
+
+//RetryableResponse returns true for the NewsAPI responses worth retrying:
+//429s and 5xxs, except those for an exhausted plan or rate limit, which
+//retrying within seconds won't get past. The body is read and replaced.
+func RetryableResponse(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return false
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return true
+	}
+	headlines := &Headlines{}
+	if json.Unmarshal(body, headlines) == nil && (headlines.Code == CodeAPIKeyExhausted || headlines.Code == CodeRateLimited) {
+		return false
+	}
+	return true
+}
+
+//NewsAPIError is an error response from NewsAPI. It matches one of the
+//ErrNewsAPI errors above with errors.Is, depending on its code.
+type NewsAPIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *NewsAPIError) Error() string {
+	return fmt.Sprintf("NewsAPI error %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+//Unwrap returns the ErrNewsAPI error for the code
+func (e *NewsAPIError) Unwrap() error {
+	switch e.Code {
+	case CodeAPIKeyDisabled, CodeAPIKeyInvalid, CodeAPIKeyMissing:
+		return ErrNewsAPIKey
+	case CodeAPIKeyExhausted, CodeRateLimited:
+		return ErrNewsAPIRateLimited
+	case CodeParameterInvalid, CodeParametersMissing, CodeSourcesTooMany, CodeSourceDoesNotExist:
+		return ErrNewsAPIRequest
+	}
+	if e.StatusCode == 429 {
+		return ErrNewsAPIRateLimited
+	}
+	return ErrNewsAPIUnavailable
+}
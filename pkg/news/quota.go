@@ -0,0 +1,152 @@
+package news
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//ErrQuotaExhausted is returned when a NewsAPI call would exceed the daily budget
+var ErrQuotaExhausted = errors.New("NewsAPI daily quota exhausted")
+
+//Priority is the priority of a NewsAPI call
+type Priority int
+
+const (
+	//PriorityRefresh is a call refreshing the cached top headlines,
+	//which every client depends on. It may use the reserved calls.
+	PriorityRefresh Priority = iota
+	//PriorityOnDemand is a call made for a single client, such as a
+	//spectrum lookup. It may only use the calls that aren't reserved.
+	PriorityOnDemand
+)
+
+//QuotaUsage is the NewsAPI usage for the current day
+type QuotaUsage struct {
+	Day       string         `json:"day"`
+	Budget    int            `json:"budget"`
+	Reserved  int            `json:"reservedForRefresh"`
+	Used      int            `json:"used"`
+	Remaining int            `json:"remaining"`
+	Endpoints map[string]int `json:"endpoints"`
+	Refresh   int            `json:"refreshCalls"`
+	OnDemand  int            `json:"onDemandCalls"`
+	//Denied is the number of calls refused because of the budget
+	Denied int `json:"denied"`
+	//StaleServed is the number of responses served from stale cache
+	StaleServed int       `json:"staleServed"`
+	ResetsAt    time.Time `json:"resetsAt"`
+}
+
+//Quota counts the NewsAPI calls made each day, which resets at midnight
+//UTC as NewsAPI's does, and refuses calls beyond the daily budget. Counts
+//are kept in memory, so they start over when the news service restarts.
+type Quota struct {
+	//Budget is the number of calls allowed each day
+	Budget int
+	//Reserved is the number of calls only refreshes may use
+	Reserved int
+
+	mx          sync.Mutex
+	day         string
+	endpoints   map[string]int
+	refresh     int
+	onDemand    int
+	denied      int
+	staleServed int
+}
+
+//NewQuota constructs a Quota allowing `budget` calls a day,
+//`reserved` of which are kept for refreshes
+func NewQuota(budget int, reserved int) *Quota {
+	return &Quota{Budget: budget, Reserved: reserved, endpoints: map[string]int{}}
+}
+
+//rollover starts a new day's counts if the day has changed.
+//The caller must hold q.mx.
+func (q *Quota) rollover() {
+	today := time.Now().UTC().Format("2006-01-02")
+	if q.day == today {
+		return
+	}
+	q.day = today
+	q.endpoints = map[string]int{}
+	q.refresh = 0
+	q.onDemand = 0
+	q.denied = 0
+	q.staleServed = 0
+}
+
+//used returns the number of calls made today. The caller must hold q.mx.
+func (q *Quota) used() int {
+	return q.refresh + q.onDemand
+}
+
+//Take counts a call to the NewsAPI endpoint, or returns
+//ErrQuotaExhausted if the budget for its priority is used up
+func (q *Quota) Take(endpoint string, priority Priority) error {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	q.rollover()
+	limit := q.Budget
+	if priority == PriorityOnDemand {
+		limit -= q.Reserved
+	}
+	if q.used() >= limit {
+		q.denied++
+		return ErrQuotaExhausted
+	}
+	q.endpoints[endpoint]++
+	if priority == PriorityRefresh {
+		q.refresh++
+	} else {
+		q.onDemand++
+	}
+	return nil
+}
+
+//ServedStale counts a response served from stale cache
+func (q *Quota) ServedStale() {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	q.rollover()
+	q.staleServed++
+}
+
+//RetryAfter returns how long until the budget resets
+func (q *Quota) RetryAfter() time.Duration {
+	return time.Until(nextReset())
+}
+
+//nextReset returns the next midnight UTC
+func nextReset() time.Time {
+	return time.Now().UTC().Truncate(time.Hour * 24).Add(time.Hour * 24)
+}
+
+//Usage returns the usage for the current day
+func (q *Quota) Usage() *QuotaUsage {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	q.rollover()
+	endpoints := map[string]int{}
+	for endpoint, count := range q.endpoints {
+		endpoints[endpoint] = count
+	}
+	remaining := q.Budget - q.used()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &QuotaUsage{
+		Day:         q.day,
+		Budget:      q.Budget,
+		Reserved:    q.Reserved,
+		Used:        q.used(),
+		Remaining:   remaining,
+		Endpoints:   endpoints,
+		Refresh:     q.refresh,
+		OnDemand:    q.onDemand,
+		Denied:      q.denied,
+		StaleServed: q.staleServed,
+		ResetsAt:    nextReset(),
+	}
+}
@@ -1,9 +1,13 @@
 package news
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/2charm/spectrum-api/pkg/tracing"
 )
 
 //Store represents a store for News related entries
@@ -14,6 +18,13 @@ type Store interface {
 	//InsertArticle inserts a new article based on category provided
 	InsertArticle(metric NewMetric, userID int64) error
 
+	//GetHistory returns every article read by a given UserID, most recent first
+	GetHistory(userID int64) ([]*ReadEvent, error)
+
+	//GetFeed returns up to `limit` articles read by any of the given
+	//users since they started sharing, most recent first
+	GetFeed(feedUsers []*FeedUser, limit int) ([]*FeedEvent, error)
+
 	//GetIDOfCategory returns the id of the category provided
 	getCategoryID(category string) (int, error)
 
@@ -23,7 +34,7 @@ type Store interface {
 
 //ArticleStore represents a SQL implemented databse for News related entries
 type ArticleStore struct {
-	Client *sql.DB
+	Client *tracing.DB
 }
 
 //NewArticleStore constructs a new ArticleStore
@@ -31,27 +42,30 @@ func NewArticleStore(db *sql.DB) *ArticleStore {
 	//initialize and return a new MySQLStore struct
 	if db != nil {
 		return &ArticleStore{
-			Client: db,
+			Client: tracing.NewDB(db),
 		}
 	}
 	return nil
 }
 
+//WithContext returns a copy of the store whose queries are traced as part of `ctx`
+func (as *ArticleStore) WithContext(ctx context.Context) *ArticleStore {
+	return &ArticleStore{Client: as.Client.WithContext(ctx)}
+}
+
 func (as *ArticleStore) GetByUserID(userID int64) (*Metrics, error) {
 	metrics := &Metrics{}
 	metrics.UserID = userID
 	rows, err := as.Client.Query("select category_name, count(*) from articles inner join categories on articles.category_id=categories.category_id where user_id=? group by category_name order by 2 desc", userID)
 	if err != nil {
-		log.Print("Error querying for categories count")
-		return nil, err
+		return nil, fmt.Errorf("error querying for categories count: %w", err)
 	}
 	metrics.CategoryToNumArticles = map[string]int{}
 	for rows.Next() {
 		var category string
 		var count int
 		if err := rows.Scan(&category, &count); err != nil {
-			log.Print("Error scanning categories")
-			return nil, err
+			return nil, fmt.Errorf("error scanning categories: %w", err)
 		}
 		metrics.CategoryToNumArticles[category] = count
 	}
@@ -71,16 +85,14 @@ func (as *ArticleStore) GetByUserID(userID int64) (*Metrics, error) {
 
 	rows, err = as.Client.Query("select source_name, count(*) from articles inner join sources on articles.source_id=sources.source_id where user_id=? group by source_name order by 2 desc", userID)
 	if err != nil {
-		log.Print("Error querying for sources count")
-		return nil, err
+		return nil, fmt.Errorf("error querying for sources count: %w", err)
 	}
 	metrics.SourceToNumArticles = map[string]int{}
 	for rows.Next() {
 		var sourceName string
 		var count int
 		if err := rows.Scan(&sourceName, &count); err != nil {
-			log.Print("Error scanning sources ")
-			return nil, err
+			return nil, fmt.Errorf("error scanning sources: %w", err)
 		}
 		metrics.SourceToNumArticles[sourceName] = count
 	}
@@ -102,17 +114,64 @@ func (as *ArticleStore) InsertArticle(metric NewMetric, userID int64) error {
 	}
 	_, err = as.Client.Exec(insq, userID, categoryID, sourceID, time.Now())
 	if err != nil {
-		log.Printf("Issue executing sql statement: %v", err)
 		return err
 	}
 	return nil
 }
 
+func (as *ArticleStore) GetHistory(userID int64) ([]*ReadEvent, error) {
+	rows, err := as.Client.Query("select category_name, source_name, read_on from articles "+
+		"inner join categories on articles.category_id=categories.category_id "+
+		"inner join sources on articles.source_id=sources.source_id "+
+		"where user_id=? order by read_on desc", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying for reading history: %w", err)
+	}
+	defer rows.Close()
+	history := []*ReadEvent{}
+	for rows.Next() {
+		event := &ReadEvent{}
+		if err := rows.Scan(&event.Category, &event.Source, &event.ReadOn); err != nil {
+			return nil, fmt.Errorf("error scanning reading history: %w", err)
+		}
+		history = append(history, event)
+	}
+	return history, rows.Err()
+}
+
+func (as *ArticleStore) GetFeed(feedUsers []*FeedUser, limit int) ([]*FeedEvent, error) {
+	feed := []*FeedEvent{}
+	if len(feedUsers) == 0 {
+		return feed, nil
+	}
+	args := make([]interface{}, 0, len(feedUsers)*2+1)
+	for _, feedUser := range feedUsers {
+		args = append(args, feedUser.UserID, feedUser.Since)
+	}
+	args = append(args, limit)
+	conditions := strings.TrimSuffix(strings.Repeat("(user_id=? and read_on>=?) or ", len(feedUsers)), " or ")
+	rows, err := as.Client.Query("select user_id, category_name, source_name, read_on from articles "+
+		"inner join categories on articles.category_id=categories.category_id "+
+		"inner join sources on articles.source_id=sources.source_id "+
+		"where "+conditions+" order by read_on desc limit ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying for feed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		event := &FeedEvent{}
+		if err := rows.Scan(&event.UserID, &event.Category, &event.Source, &event.ReadOn); err != nil {
+			return nil, fmt.Errorf("error scanning feed: %w", err)
+		}
+		feed = append(feed, event)
+	}
+	return feed, rows.Err()
+}
+
 func (as *ArticleStore) insertSource(sourceName string) (int, error) {
 	insq := "insert into sources(source_name) values (?)"
 	res, err := as.Client.Exec(insq, sourceName)
 	if err != nil {
-		log.Printf("Issue executing sql statement: %v", err)
 		return -1, err
 	}
 
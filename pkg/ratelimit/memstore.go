@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+//bucket is the state of a token bucket
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+//MemStore represents an in-process memory Store. Each gateway instance
+//has its own buckets, so use a RedisStore when running more than one.
+type MemStore struct {
+	buckets *cache.Cache
+	mx      sync.Mutex
+}
+
+//NewMemStore constructs and returns a new MemStore. Full buckets
+//are removed every `purgeInterval`.
+func NewMemStore(purgeInterval time.Duration) *MemStore {
+	return &MemStore{buckets: cache.New(cache.NoExpiration, purgeInterval)}
+}
+
+//Take refills the bucket for the given policy and key for the time
+//since it was last used, then takes a token from it if one is left
+func (ms *MemStore) Take(policy *Policy, key string) (*Result, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	now := time.Now()
+	id := policy.Name + ":" + key
+	b := &bucket{tokens: float64(policy.Burst), updatedAt: now}
+	if found, exists := ms.buckets.Get(id); exists {
+		b = found.(*bucket)
+	}
+	b.tokens = math.Min(float64(policy.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*policy.rate())
+	b.updatedAt = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	result := newResult(policy, b.tokens, allowed)
+	//once the bucket is full again it's the same as a new one
+	ms.buckets.Set(id, b, result.Reset+time.Second)
+	return result, nil
+}
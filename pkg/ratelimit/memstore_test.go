@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+//rewind moves the bucket's last update back by `d`, as if that much time passed
+func rewind(t *testing.T, ms *MemStore, policy *Policy, key string, d time.Duration) {
+	found, exists := ms.buckets.Get(policy.Name + ":" + key)
+	if !exists {
+		t.Fatalf("no bucket for %s", key)
+	}
+	found.(*bucket).updatedAt = found.(*bucket).updatedAt.Add(-d)
+}
+
+func TestMemStoreBurst(t *testing.T) {
+	ms := NewMemStore(time.Minute)
+	policy := NewPolicy("test", 3, time.Minute)
+	for i := 2; i >= 0; i-- {
+		result, err := ms.Take(policy, "a")
+		if err != nil {
+			t.Fatalf("Take: unexpected error %v", err)
+		}
+		if !result.Allowed || result.Remaining != i {
+			t.Errorf("Take: got allowed %v with %d remaining, want true with %d", result.Allowed, result.Remaining, i)
+		}
+	}
+	result, _ := ms.Take(policy, "a")
+	if result.Allowed || result.Remaining != 0 {
+		t.Errorf("Take from an empty bucket: got allowed %v with %d remaining", result.Allowed, result.Remaining)
+	}
+	//one token is added every 20 seconds
+	if result.RetryAfter <= 19*time.Second || result.RetryAfter > 20*time.Second {
+		t.Errorf("RetryAfter: got %v, want about 20s", result.RetryAfter)
+	}
+	if result.Reset <= 59*time.Second || result.Reset > time.Minute {
+		t.Errorf("Reset: got %v, want about 1m", result.Reset)
+	}
+
+	//buckets are kept per key
+	if result, _ := ms.Take(policy, "b"); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("Take for another key: got allowed %v with %d remaining", result.Allowed, result.Remaining)
+	}
+}
+
+func TestMemStoreRefill(t *testing.T) {
+	ms := NewMemStore(time.Minute)
+	policy := NewPolicy("test", 3, time.Minute)
+	for i := 0; i < 3; i++ {
+		ms.Take(policy, "a")
+	}
+
+	//half a token's refill isn't enough, and the wait shrinks
+	rewind(t, ms, policy, "a", 10*time.Second)
+	result, _ := ms.Take(policy, "a")
+	if result.Allowed {
+		t.Error("Take after half a token refilled: got allowed")
+	}
+	if result.RetryAfter <= 9*time.Second || result.RetryAfter > 10*time.Second {
+		t.Errorf("RetryAfter: got %v, want about 10s", result.RetryAfter)
+	}
+
+	rewind(t, ms, policy, "a", 10*time.Second)
+	if result, _ := ms.Take(policy, "a"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Take after a token refilled: got allowed %v with %d remaining", result.Allowed, result.Remaining)
+	}
+
+	//refilling stops at the burst
+	rewind(t, ms, policy, "a", time.Hour)
+	result, _ = ms.Take(policy, "a")
+	if !result.Allowed || result.Remaining != 2 {
+		t.Errorf("Take after a long wait: got allowed %v with %d remaining, want true with 2", result.Allowed, result.Remaining)
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	//one token is added each second
+	policy := &Policy{Name: "test", Limit: 10, Period: 10 * time.Second, Burst: 5}
+	cases := []struct {
+		tokens     float64
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{4, true, 4, 0, time.Second},
+		{0, true, 0, 0, 5 * time.Second},
+		{0.5, false, 0, 500 * time.Millisecond, 4500 * time.Millisecond},
+		{0, false, 0, time.Second, 5 * time.Second},
+	}
+	for _, c := range cases {
+		result := newResult(policy, c.tokens, c.allowed)
+		if result.Allowed != c.allowed || result.Remaining != c.remaining ||
+			result.RetryAfter != c.retryAfter || result.Reset != c.reset {
+			t.Errorf("newResult(%v, %v): got %+v, want remaining %d, retry after %v, reset %v",
+				c.tokens, c.allowed, result, c.remaining, c.retryAfter, c.reset)
+		}
+	}
+}
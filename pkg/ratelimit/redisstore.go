@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//takeScript refills and takes a token from the bucket at KEYS[1] atomically.
+//ARGV holds the refill rate in tokens per millisecond, the burst and the
+//current time in milliseconds. It returns whether a token was taken and the
+//tokens left, as a string since redis truncates Lua numbers to integers.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+//RedisStore represents a Store backed by redis, so that every gateway
+//instance shares the same buckets
+type RedisStore struct {
+	//Redis client used to talk to redis server.
+	Client *redis.Client
+}
+
+//NewRedisStore constructs a new RedisStore
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{Client: client}
+}
+
+//Take refills the bucket for the given policy and key for the time
+//since it was last used, then takes a token from it if one is left
+func (rs *RedisStore) Take(policy *Policy, key string) (*Result, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	reply, err := takeScript.Run(rs.Client, []string{"ratelimit:" + policy.Name + ":" + key},
+		policy.rate()/1000, policy.Burst, now).Result()
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("unexpected reply from rate limit script: %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(policy, tokens, allowed == 1), nil
+}
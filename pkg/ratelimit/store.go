@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+//Policy is a token bucket that holds up to Burst tokens and refills
+//at Limit tokens per Period. Each request takes one token.
+type Policy struct {
+	//Name identifies the policy in stored keys and the RateLimit-Policy header
+	Name   string
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+//NewPolicy constructs a Policy allowing `limit` requests per `period`,
+//all of which may be made at once
+func NewPolicy(name string, limit int, period time.Duration) *Policy {
+	return &Policy{Name: name, Limit: limit, Period: period, Burst: limit}
+}
+
+//rate returns the number of tokens added to the bucket per second
+func (p *Policy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+//Result is the outcome of taking a token from a bucket
+type Result struct {
+	//Allowed is true if a token was taken
+	Allowed bool
+	//Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	//RetryAfter is how long until a token is available, if none were left
+	RetryAfter time.Duration
+	//Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+//newResult computes the Result of a take that left `tokens` in the bucket
+func newResult(policy *Policy, tokens float64, allowed bool) *Result {
+	result := &Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsToDuration((float64(policy.Burst) - tokens) / policy.rate()),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / policy.rate())
+	}
+	return result
+}
+
+//secondsToDuration converts a number of seconds to a Duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+//Store represents a store of token buckets, each identified by a policy and key
+type Store interface {
+	//Take refills the bucket for the given policy and key for the time
+	//since it was last used, then takes a token from it if one is left
+	Take(policy *Policy, key string) (*Result, error)
+}
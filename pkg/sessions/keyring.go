@@ -0,0 +1,186 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//retiredFlag marks a key that may no longer validate session IDs
+const retiredFlag = ":retired"
+
+//maxKeyIDLength is the longest key ID that fits in a session ID
+const maxKeyIDLength = 255
+
+//ErrNoCurrentKey is returned when a key ring has no usable signing key
+var ErrNoCurrentKey = errors.New("key ring has no current, non-retired key")
+
+//Key is a signing key identified by a key ID
+type Key struct {
+	ID      string
+	Secret  []byte
+	Retired bool
+}
+
+//KeyRing holds the keys used to sign and validate session IDs.
+//New session IDs are signed with the current key, and IDs signed
+//with any key that hasn't been retired remain valid, so keys can
+//be rotated without ending every live session.
+type KeyRing struct {
+	mx      sync.RWMutex
+	current *Key
+	keys    map[string]*Key
+	//path and modTime track the file the ring was loaded from, if any
+	path    string
+	modTime time.Time
+}
+
+//NewKeyRing constructs a KeyRing from the given keys.
+//The first key is the current signing key.
+func NewKeyRing(keys ...*Key) (*KeyRing, error) {
+	kr := &KeyRing{}
+	if err := kr.set(keys); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+//ParseKeyRing constructs a KeyRing from a list of keys separated by
+//commas or newlines. Each key has the form "id:secret", optionally
+//followed by ":retired". The first key is the current signing key.
+//Blank lines and lines starting with # are ignored.
+func ParseKeyRing(spec string) (*KeyRing, error) {
+	keys, err := parseKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyRing(keys...)
+}
+
+//LoadKeyRing constructs a KeyRing from the keys in the file at `path`,
+//in the format accepted by ParseKeyRing. Call Reload to pick up changes.
+func LoadKeyRing(path string) (*KeyRing, error) {
+	kr := &KeyRing{path: path}
+	if _, err := kr.Reload(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+//Reload re-reads the key file if it changed since it was last loaded,
+//and returns true if the keys were replaced. The ring keeps its previous
+//keys if the file can't be read or parsed.
+func (kr *KeyRing) Reload() (bool, error) {
+	if kr.path == "" {
+		return false, nil
+	}
+	info, err := os.Stat(kr.path)
+	if err != nil {
+		return false, err
+	}
+	kr.mx.RLock()
+	unchanged := info.ModTime().Equal(kr.modTime)
+	kr.mx.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	contents, err := ioutil.ReadFile(kr.path)
+	if err != nil {
+		return false, err
+	}
+	keys, err := parseKeys(string(contents))
+	if err != nil {
+		return false, err
+	}
+	if err := kr.set(keys); err != nil {
+		return false, err
+	}
+	kr.mx.Lock()
+	kr.modTime = info.ModTime()
+	kr.mx.Unlock()
+	return true, nil
+}
+
+//Current returns the key new session IDs are signed with
+func (kr *KeyRing) Current() *Key {
+	kr.mx.RLock()
+	defer kr.mx.RUnlock()
+	return kr.current
+}
+
+//Get returns the key with the given ID, if it exists and isn't retired
+func (kr *KeyRing) Get(id string) (*Key, bool) {
+	kr.mx.RLock()
+	defer kr.mx.RUnlock()
+	key, found := kr.keys[id]
+	if !found || key.Retired {
+		return nil, false
+	}
+	return key, true
+}
+
+//Active returns every key that isn't retired
+func (kr *KeyRing) Active() []*Key {
+	kr.mx.RLock()
+	defer kr.mx.RUnlock()
+	active := []*Key{}
+	for _, key := range kr.keys {
+		if !key.Retired {
+			active = append(active, key)
+		}
+	}
+	return active
+}
+
+//set replaces the keys in the ring. The first key becomes current.
+func (kr *KeyRing) set(keys []*Key) error {
+	if len(keys) == 0 || keys[0].Retired || len(keys[0].Secret) == 0 {
+		return ErrNoCurrentKey
+	}
+	byID := map[string]*Key{}
+	for _, key := range keys {
+		if key.ID == "" || len(key.ID) > maxKeyIDLength {
+			return fmt.Errorf("key ID must be between 1 and %d characters", maxKeyIDLength)
+		}
+		if _, dup := byID[key.ID]; dup {
+			return fmt.Errorf("duplicate key ID %s", key.ID)
+		}
+		byID[key.ID] = key
+	}
+	kr.mx.Lock()
+	defer kr.mx.Unlock()
+	kr.current = keys[0]
+	kr.keys = byID
+	return nil
+}
+
+//parseKeys parses keys in the format accepted by ParseKeyRing
+func parseKeys(spec string) ([]*Key, error) {
+	keys := []*Key{}
+	entries := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == ',' || r == '\n'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		key := &Key{}
+		if strings.HasSuffix(entry, retiredFlag) {
+			key.Retired = true
+			entry = strings.TrimSuffix(entry, retiredFlag)
+		}
+		fields := strings.SplitN(entry, ":", 2)
+		if len(fields) != 2 || fields[1] == "" {
+			return nil, fmt.Errorf("key must have the form id:secret")
+		}
+		key.ID = fields[0]
+		key.Secret = []byte(fields[1])
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
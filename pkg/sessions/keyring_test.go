@@ -0,0 +1,172 @@
+package sessions
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//legacySessionID signs a session ID in the format used before key IDs were added
+func legacySessionID(t *testing.T, secret string) string {
+	id := make([]byte, idLength)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatalf("rand.Read: unexpected error %v", err)
+	}
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(id)
+	return base64.URLEncoding.EncodeToString(h.Sum(id))
+}
+
+func mustParseKeyRing(t *testing.T, spec string) *KeyRing {
+	keys, err := ParseKeyRing(spec)
+	if err != nil {
+		t.Fatalf("ParseKeyRing(%q): unexpected error %v", spec, err)
+	}
+	return keys
+}
+
+func TestParseKeyRing(t *testing.T) {
+	keys := mustParseKeyRing(t, "# keys\nk2:second\n\nk1:first:retired,k0:zeroth")
+	if current := keys.Current(); current.ID != "k2" || string(current.Secret) != "second" {
+		t.Errorf("Current: got %s:%s, want k2:second", current.ID, current.Secret)
+	}
+	if _, found := keys.Get("k1"); found {
+		t.Error("Get: found the retired key k1")
+	}
+	if key, found := keys.Get("k0"); !found || string(key.Secret) != "zeroth" {
+		t.Error("Get: didn't find the active key k0")
+	}
+	if active := keys.Active(); len(active) != 2 {
+		t.Errorf("Active: got %d keys, want 2", len(active))
+	}
+
+	invalid := map[string]string{
+		"empty":           "",
+		"retired current": "k1:first:retired,k0:zeroth",
+		"no secret":       "k1:",
+		"no separator":    "k1",
+		"duplicate ID":    "k1:first,k1:second",
+		"empty ID":        ":secret",
+	}
+	for name, spec := range invalid {
+		if _, err := ParseKeyRing(spec); err == nil {
+			t.Errorf("%s: ParseKeyRing(%q) got no error", name, spec)
+		}
+	}
+}
+
+func TestValidateLegacyID(t *testing.T) {
+	legacy := legacySessionID(t, "first")
+	if _, err := ValidateID(legacy, mustParseKeyRing(t, "k2:second,k1:first")); err != nil {
+		t.Errorf("legacy ID signed by an active key: unexpected error %v", err)
+	}
+	if _, err := ValidateID(legacy, mustParseKeyRing(t, "k2:second,k1:first:retired")); err != ErrInvalidID {
+		t.Errorf("legacy ID signed by a retired key: got %v, want ErrInvalidID", err)
+	}
+	if _, err := ValidateID(legacy, mustParseKeyRing(t, "k2:second")); err != ErrInvalidID {
+		t.Errorf("legacy ID signed by an unknown key: got %v, want ErrInvalidID", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	before := mustParseKeyRing(t, "k1:first")
+	sid, err := NewSessionID(before)
+	if err != nil {
+		t.Fatalf("NewSessionID: unexpected error %v", err)
+	}
+
+	//k2 becomes current, and IDs signed with k1 stay valid
+	rotated := mustParseKeyRing(t, "k2:second,k1:first")
+	if _, err := ValidateID(sid.String(), rotated); err != nil {
+		t.Errorf("ID signed by the previous key: unexpected error %v", err)
+	}
+	newSID, err := NewSessionID(rotated)
+	if err != nil {
+		t.Fatalf("NewSessionID: unexpected error %v", err)
+	}
+	if _, err := ValidateID(newSID.String(), before); err != ErrInvalidID {
+		t.Errorf("ID signed by a key the ring doesn't hold: got %v, want ErrInvalidID", err)
+	}
+
+	//retiring k1 ends the sessions signed with it
+	retired := mustParseKeyRing(t, "k2:second,k1:first:retired")
+	if _, err := ValidateID(sid.String(), retired); err != ErrInvalidID {
+		t.Errorf("ID signed by a retired key: got %v, want ErrInvalidID", err)
+	}
+	if _, err := ValidateID(newSID.String(), retired); err != nil {
+		t.Errorf("ID signed by the current key: unexpected error %v", err)
+	}
+
+	//a secret reused under another key ID doesn't validate the ID
+	renamed := mustParseKeyRing(t, "k3:first")
+	if _, err := ValidateID(sid.String(), renamed); err != ErrInvalidID {
+		t.Errorf("ID whose key ID isn't in the ring: got %v, want ErrInvalidID", err)
+	}
+}
+
+func TestValidateTamperedID(t *testing.T) {
+	keys := mustParseKeyRing(t, "k1:first")
+	sid, err := NewSessionID(keys)
+	if err != nil {
+		t.Fatalf("NewSessionID: unexpected error %v", err)
+	}
+	decoded, _ := base64.URLEncoding.DecodeString(sid.String())
+	decoded[len(decoded)/2] ^= 0xff
+	cases := map[string]string{
+		"tampered": base64.URLEncoding.EncodeToString(decoded),
+		"empty":    "",
+		"short":    base64.URLEncoding.EncodeToString(decoded[:10]),
+	}
+	for name, id := range cases {
+		if _, err := ValidateID(id, keys); err != ErrInvalidID {
+			t.Errorf("%s: got %v, want ErrInvalidID", name, err)
+		}
+	}
+	if _, err := ValidateID("not base64!", keys); err == nil {
+		t.Error("invalid base64: got no error")
+	}
+}
+
+func TestLoadKeyRingReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("k1:first\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error %v", err)
+	}
+	keys, err := LoadKeyRing(path)
+	if err != nil {
+		t.Fatalf("LoadKeyRing: unexpected error %v", err)
+	}
+	if reloaded, err := keys.Reload(); reloaded || err != nil {
+		t.Errorf("Reload of an unchanged file: got %v, %v, want false, nil", reloaded, err)
+	}
+
+	modified := time.Now().Add(time.Minute)
+	if err := os.WriteFile(path, []byte("k2:second\nk1:first\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error %v", err)
+	}
+	os.Chtimes(path, modified, modified)
+	if reloaded, err := keys.Reload(); !reloaded || err != nil {
+		t.Errorf("Reload of a changed file: got %v, %v, want true, nil", reloaded, err)
+	}
+	if keys.Current().ID != "k2" {
+		t.Errorf("Current after Reload: got %s, want k2", keys.Current().ID)
+	}
+
+	//the ring keeps its keys when the file is invalid
+	modified = modified.Add(time.Minute)
+	if err := os.WriteFile(path, []byte("k3\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error %v", err)
+	}
+	os.Chtimes(path, modified, modified)
+	if _, err := keys.Reload(); err == nil {
+		t.Error("Reload of an invalid file: got no error")
+	}
+	if keys.Current().ID != "k2" {
+		t.Errorf("Current after a failed Reload: got %s, want k2", keys.Current().ID)
+	}
+}
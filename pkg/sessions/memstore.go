@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -12,12 +13,16 @@ import (
 //Production systems should use a shared server store like redis
 type MemStore struct {
 	entries *cache.Cache
+	//userSessions indexes SessionIDs by user ID
+	userSessions map[int64]map[SessionID]bool
+	mx           sync.Mutex
 }
 
 //NewMemStore constructs and returns a new MemStore
 func NewMemStore(sessionDuration time.Duration, purgeInterval time.Duration) *MemStore {
 	return &MemStore{
-		entries: cache.New(sessionDuration, purgeInterval),
+		entries:      cache.New(sessionDuration, purgeInterval),
+		userSessions: map[int64]map[SessionID]bool{},
 	}
 }
 
@@ -50,3 +55,53 @@ func (ms *MemStore) Delete(sid SessionID) error {
 	ms.entries.Delete(sid.String())
 	return nil
 }
+
+//Take populates `sessionState` with the data saved for the given
+//SessionID and deletes it
+func (ms *MemStore) Take(sid SessionID, state interface{}) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	j, found := ms.entries.Get(sid.String())
+	if !found {
+		return ErrStateNotFound
+	}
+	ms.entries.Delete(sid.String())
+	return json.Unmarshal(j.([]byte), state)
+}
+
+//AddToUser records the SessionID in the index of sessions
+//belonging to the given user ID
+func (ms *MemStore) AddToUser(userID int64, sid SessionID) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if ms.userSessions[userID] == nil {
+		ms.userSessions[userID] = map[SessionID]bool{}
+	}
+	ms.userSessions[userID][sid] = true
+	return nil
+}
+
+//GetUserSessions returns the SessionIDs in the given user's index
+//that still have state in the store
+func (ms *MemStore) GetUserSessions(userID int64) ([]SessionID, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	sids := []SessionID{}
+	for sid := range ms.userSessions[userID] {
+		if _, found := ms.entries.Get(sid.String()); !found {
+			//session expired or was deleted, drop it from the index
+			delete(ms.userSessions[userID], sid)
+			continue
+		}
+		sids = append(sids, sid)
+	}
+	return sids, nil
+}
+
+//RemoveFromUser removes the SessionID from the given user's index
+func (ms *MemStore) RemoveFromUser(userID int64, sid SessionID) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	delete(ms.userSessions[userID], sid)
+	return nil
+}
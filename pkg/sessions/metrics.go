@@ -0,0 +1,124 @@
+package sessions
+
+import (
+	"context"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var storeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spectrum",
+	Name:      "session_store_duration_seconds",
+	Help:      "Time taken by session store operations, by operation.",
+	Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+}, []string{"operation"})
+
+var storeLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spectrum",
+	Name:      "session_store_lookups_total",
+	Help:      "Session state lookups, by whether the state was found.",
+}, []string{"result"})
+
+//InstrumentedStore is a Store that records the latency of each
+//operation on the Store it wraps, and whether lookups found state.
+//Operations are traced as children of the span in its context.
+type InstrumentedStore struct {
+	Store Store
+	ctx   context.Context
+}
+
+//NewInstrumentedStore wraps `store` with metrics and tracing
+func NewInstrumentedStore(store Store) *InstrumentedStore {
+	return &InstrumentedStore{Store: store, ctx: context.Background()}
+}
+
+//WithContext returns a copy of the store whose operations are traced as part of `ctx`
+func (is *InstrumentedStore) WithContext(ctx context.Context) Store {
+	return &InstrumentedStore{Store: is.Store, ctx: ctx}
+}
+
+//contextStore is implemented by stores that can trace their operations
+type contextStore interface {
+	WithContext(ctx context.Context) Store
+}
+
+//WithContext returns a view of `store` whose operations are traced as
+//part of `ctx`, or `store` itself if it doesn't support tracing
+func WithContext(store Store, ctx context.Context) Store {
+	if cs, ok := store.(contextStore); ok {
+		return cs.WithContext(ctx)
+	}
+	return store
+}
+
+//observe starts a span for the operation, and returns a function that
+//ends it and records the operation's latency
+func (is *InstrumentedStore) observe(operation string) func(error) {
+	start := time.Now()
+	_, span := tracing.Start(is.ctx, "session."+operation)
+	return func(err error) {
+		storeDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		if err == ErrStateNotFound {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
+
+func (is *InstrumentedStore) Save(sid SessionID, sessionState interface{}) error {
+	done := is.observe("save")
+	err := is.Store.Save(sid, sessionState)
+	done(err)
+	return err
+}
+
+func (is *InstrumentedStore) Get(sid SessionID, sessionState interface{}) error {
+	done := is.observe("get")
+	err := is.Store.Get(sid, sessionState)
+	done(err)
+	if err == ErrStateNotFound {
+		storeLookups.WithLabelValues("miss").Inc()
+	} else if err == nil {
+		storeLookups.WithLabelValues("hit").Inc()
+	}
+	return err
+}
+
+func (is *InstrumentedStore) Delete(sid SessionID) error {
+	done := is.observe("delete")
+	err := is.Store.Delete(sid)
+	done(err)
+	return err
+}
+
+func (is *InstrumentedStore) Take(sid SessionID, sessionState interface{}) error {
+	done := is.observe("take")
+	err := is.Store.Take(sid, sessionState)
+	done(err)
+	return err
+}
+
+func (is *InstrumentedStore) AddToUser(userID int64, sid SessionID) error {
+	done := is.observe("add_to_user")
+	err := is.Store.AddToUser(userID, sid)
+	done(err)
+	return err
+}
+
+func (is *InstrumentedStore) GetUserSessions(userID int64) ([]SessionID, error) {
+	done := is.observe("get_user_sessions")
+	sids, err := is.Store.GetUserSessions(userID)
+	done(err)
+	return sids, err
+}
+
+func (is *InstrumentedStore) RemoveFromUser(userID int64, sid SessionID) error {
+	done := is.observe("remove_from_user")
+	err := is.Store.RemoveFromUser(userID, sid)
+	done(err)
+	return err
+}
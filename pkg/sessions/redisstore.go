@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -74,6 +75,68 @@ func (rs *RedisStore) Delete(sid SessionID) error {
 	return nil
 }
 
+//Take populates `sessionState` with the data saved for the given
+//SessionID and deletes it, in a single MULTI/EXEC transaction
+func (rs *RedisStore) Take(sid SessionID, sessionState interface{}) error {
+	pipe := rs.Client.TxPipeline()
+	state := pipe.Get(sid.getRedisKey())
+	pipe.Del(sid.getRedisKey())
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return err
+	}
+	stateBytes, err := state.Bytes()
+	if err != nil {
+		return ErrStateNotFound
+	}
+	return json.Unmarshal(stateBytes, sessionState)
+}
+
+//AddToUser records the SessionID in the index of sessions
+//belonging to the given user ID. The index doesn't expire, since Get
+//keeps sessions in use alive past SessionDuration; GetUserSessions
+//drops the IDs of sessions that have expired instead.
+func (rs *RedisStore) AddToUser(userID int64, sid SessionID) error {
+	return rs.Client.SAdd(getUserRedisKey(userID), sid.String()).Err()
+}
+
+//GetUserSessions returns the SessionIDs in the given user's index
+//that still have state in the store
+func (rs *RedisStore) GetUserSessions(userID int64) ([]SessionID, error) {
+	members, err := rs.Client.SMembers(getUserRedisKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	pipe := rs.Client.Pipeline()
+	exists := make([]*redis.IntCmd, len(members))
+	for i, member := range members {
+		exists[i] = pipe.Exists(SessionID(member).getRedisKey())
+	}
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	sids := []SessionID{}
+	for i, member := range members {
+		if exists[i].Val() == 0 {
+			//session expired or was deleted, drop it from the index
+			rs.Client.SRem(getUserRedisKey(userID), member)
+			continue
+		}
+		sids = append(sids, SessionID(member))
+	}
+	return sids, nil
+}
+
+//RemoveFromUser removes the SessionID from the given user's index
+func (rs *RedisStore) RemoveFromUser(userID int64, sid SessionID) error {
+	return rs.Client.SRem(getUserRedisKey(userID), sid.String()).Err()
+}
+
+//getUserRedisKey returns the redis key of the set of SessionIDs
+//belonging to the given user ID
+func getUserRedisKey(userID int64) string {
+	return fmt.Sprintf("usid:%d", userID)
+}
+
 //getRedisKey() returns the redis key to use for the SessionID
 func (sid SessionID) getRedisKey() string {
 	//convert the SessionID to a string and add the prefix "sid:" to keep
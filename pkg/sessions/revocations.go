@@ -0,0 +1,93 @@
+package sessions
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//Revocations records when users changed in ways their sessions and
+//access tokens must notice, such as being suspended, so the user kept in
+//a session's state only needs reloading when it's older than the change
+type Revocations interface {
+	//Revoke records that the given user ID changed now
+	Revoke(userID int64) error
+
+	//RevokedAt returns when the given user ID last changed,
+	//or the zero Time if they haven't changed recently
+	RevokedAt(userID int64) (time.Time, error)
+}
+
+//RedisRevocations represents Revocations backed by redis
+type RedisRevocations struct {
+	Client *redis.Client
+	//TTL is how long a revocation is kept. It must be at least as long
+	//as any session state goes without its user being reloaded.
+	TTL time.Duration
+}
+
+//NewRedisRevocations constructs a new RedisRevocations
+func NewRedisRevocations(client *redis.Client, ttl time.Duration) *RedisRevocations {
+	return &RedisRevocations{
+		Client: client,
+		TTL:    ttl,
+	}
+}
+
+//Revoke records that the given user ID changed now
+func (rr *RedisRevocations) Revoke(userID int64) error {
+	return rr.Client.Set(getRevocationRedisKey(userID), time.Now().UnixNano(), rr.TTL).Err()
+}
+
+//RevokedAt returns when the given user ID last changed,
+//or the zero Time if they haven't changed within the TTL
+func (rr *RedisRevocations) RevokedAt(userID int64) (time.Time, error) {
+	value, err := rr.Client.Get(getRevocationRedisKey(userID)).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	nanos, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos), nil
+}
+
+//getRevocationRedisKey returns the redis key of the given user ID's last revocation
+func getRevocationRedisKey(userID int64) string {
+	return fmt.Sprintf("revoked:%d", userID)
+}
+
+//MemRevocations represents in-process memory Revocations.
+//This should be used only for testing and prototyping.
+type MemRevocations struct {
+	revokedAt map[int64]time.Time
+	mx        sync.Mutex
+}
+
+//NewMemRevocations constructs and returns a new MemRevocations
+func NewMemRevocations() *MemRevocations {
+	return &MemRevocations{revokedAt: map[int64]time.Time{}}
+}
+
+//Revoke records that the given user ID changed now
+func (mr *MemRevocations) Revoke(userID int64) error {
+	mr.mx.Lock()
+	defer mr.mx.Unlock()
+	mr.revokedAt[userID] = time.Now()
+	return nil
+}
+
+//RevokedAt returns when the given user ID last changed,
+//or the zero Time if they haven't changed
+func (mr *MemRevocations) RevokedAt(userID int64) (time.Time, error) {
+	mr.mx.Lock()
+	defer mr.mx.Unlock()
+	return mr.revokedAt[userID], nil
+}
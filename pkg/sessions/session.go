@@ -1,25 +1,78 @@
 package sessions
 
 import (
+	"crypto/ed25519"
+	"encoding/json"
 	"errors"
 	"net/http"
-	"strings"
+	"time"
 )
 
 const headerAuthorization = "Authorization"
+const headerRefreshToken = "Refresh-Token"
 const paramAuthorization = "auth"
 const schemeBearer = "Bearer "
 
-//ErrNoSessionID is used when no session ID was found in the Authorization header
-var ErrNoSessionID = errors.New("no session ID found in " + headerAuthorization + " header")
+//Mode selects how sessions are issued to and presented by clients
+type Mode int
+
+const (
+	//ModeSession issues a signed SessionID, and every request
+	//looks up the session state in the Store
+	ModeSession Mode = iota
+	//ModeToken issues a short-lived signed access token carrying the
+	//session state, plus a refresh token whose state is kept in the Store
+	ModeToken
+)
+
+//Config holds the settings used to begin and validate sessions
+type Config struct {
+	//Keys are the HMAC keys for SessionIDs and HS256 access tokens
+	Keys *KeyRing
+	//Mode selects session or token mode
+	Mode Mode
+	//AccessTokenDuration is how long access tokens are valid in ModeToken
+	AccessTokenDuration time.Duration
+	//Algorithm signs access tokens, either AlgHS256 or AlgEdDSA
+	Algorithm string
+	//EdDSAKey is the private key used when Algorithm is AlgEdDSA
+	EdDSAKey ed25519.PrivateKey
+	//Transport selects header or cookie credentials
+	Transport Transport
+	//CookieDomain and SameSite apply to cookies in TransportCookie
+	CookieDomain string
+	SameSite     http.SameSite
+}
+
+//ErrNoSessionID is used when no session ID was found in the request
+var ErrNoSessionID = errors.New("no session ID found in request")
 
 //ErrInvalidScheme is used when the authorization scheme is not supported
 var ErrInvalidScheme = errors.New("authorization scheme not supported")
 
+//ErrNoRefreshToken is used when no refresh token was found in the request
+var ErrNoRefreshToken = errors.New("no refresh token found in request")
+
+//ErrRefreshReused is returned when a refresh token that was already rotated
+//is presented again. Every token descended from it is revoked, since
+//either the client or an attacker holds a stolen copy.
+var ErrRefreshReused = errors.New("refresh token was already used")
+
+//rotation records the key of the refresh token that replaced a rotated one
+type rotation struct {
+	ReplacedBy SessionID `json:"replacedBy"`
+}
+
 //BeginSession creates a new SessionID, saves the `sessionState` to the store, adds an
-//Authorization header to the response with the SessionID, and returns the new SessionID
-func BeginSession(signingKey string, store Store, sessionState interface{}, w http.ResponseWriter) (SessionID, error) {
-	sid, err := NewSessionID(signingKey)
+//Authorization header to the response with the SessionID, and returns the new SessionID.
+//In ModeToken the Authorization header carries an access token instead, a Refresh-Token
+//header carries the refresh token, and the returned SessionID is the refresh token's
+//key in the store. In TransportCookie the credentials are set as cookies instead.
+func BeginSession(cfg *Config, store Store, sessionState interface{}, w http.ResponseWriter) (SessionID, error) {
+	if cfg.Mode == ModeToken {
+		return beginTokenSession(cfg, store, sessionState, w)
+	}
+	sid, err := NewSessionID(cfg.Keys)
 	if err != nil {
 		return InvalidSessionID, err
 	}
@@ -29,30 +82,42 @@ func BeginSession(signingKey string, store Store, sessionState interface{}, w ht
 		return InvalidSessionID, err
 	}
 
-	w.Header().Add(headerAuthorization, schemeBearer+sid.String())
+	if err := setCredentials(w, cfg, sid.String(), ""); err != nil {
+		return InvalidSessionID, err
+	}
 
 	return sid, nil
 }
 
-//GetSessionID extracts and validates the SessionID from the request headers
-func GetSessionID(r *http.Request, signingKey string) (SessionID, error) {
-	//TODO: get the value of the Authorization header,
-	//or the "auth" query string parameter if no Authorization header is present,
-	//and validate it. If it's valid, return the SessionID. If not
-	//return the validation error.
-	sid := r.Header.Get(headerAuthorization)
-	if sid == "" {
-		sid = r.URL.Query().Get(paramAuthorization)
-		if sid == "" {
-			return InvalidSessionID, ErrNoSessionID
-		}
+//beginTokenSession saves the `sessionState` under a new refresh token
+//and adds the access and refresh tokens to the response
+func beginTokenSession(cfg *Config, store Store, sessionState interface{}, w http.ResponseWriter) (SessionID, error) {
+	refresh, err := NewSessionID(cfg.Keys)
+	if err != nil {
+		return InvalidSessionID, err
 	}
-	if !strings.HasPrefix(sid, schemeBearer) {
-		return InvalidSessionID, ErrInvalidScheme
+	key := refresh.refreshKey()
+	if err := store.Save(key, sessionState); err != nil {
+		return InvalidSessionID, err
 	}
-	sid = strings.TrimPrefix(sid, schemeBearer)
-	// return ValidateID(sid, signingKey)
-	sidValid, err := ValidateID(sid, signingKey)
+	access, err := NewAccessToken(cfg, key, sessionState)
+	if err != nil {
+		return InvalidSessionID, err
+	}
+	if err := setCredentials(w, cfg, access, refresh.String()); err != nil {
+		return InvalidSessionID, err
+	}
+	return key, nil
+}
+
+//GetSessionID extracts and validates the SessionID from the request
+//using the configured transport
+func GetSessionID(r *http.Request, cfg *Config) (SessionID, error) {
+	sid, err := getCredential(r, cfg)
+	if err != nil {
+		return InvalidSessionID, err
+	}
+	sidValid, err := ValidateID(sid, cfg.Keys)
 	if err != nil {
 		return InvalidSessionID, err
 	}
@@ -61,11 +126,25 @@ func GetSessionID(r *http.Request, signingKey string) (SessionID, error) {
 
 //GetState extracts the SessionID from the request,
 //gets the associated state from the provided store into
-//the `sessionState` parameter, and returns the SessionID
-func GetState(r *http.Request, signingKey string, store Store, sessionState interface{}) (SessionID, error) {
-	//TODO: get the SessionID from the request, and get the data
-	//associated with that SessionID from the store.
-	sid, err := GetSessionID(r, signingKey)
+//the `sessionState` parameter, and returns the SessionID.
+//In ModeToken the state is read from the access token
+//without touching the store.
+func GetState(r *http.Request, cfg *Config, store Store, sessionState interface{}) (SessionID, error) {
+	store = WithContext(store, r.Context())
+	if cfg.Mode == ModeToken {
+		token, err := getCredential(r, cfg)
+		if err == ErrInvalidCSRF {
+			return InvalidSessionID, err
+		}
+		if err != nil {
+			return InvalidSessionID, ErrNoSessionID
+		}
+		return ParseAccessToken(cfg, token, sessionState)
+	}
+	sid, err := GetSessionID(r, cfg)
+	if err == ErrInvalidCSRF {
+		return InvalidSessionID, err
+	}
 	if err != nil {
 		return InvalidSessionID, ErrNoSessionID
 	}
@@ -79,15 +158,76 @@ func GetState(r *http.Request, signingKey string, store Store, sessionState inte
 
 //EndSession extracts the SessionID from the request,
 //and deletes the associated data in the provided store, returning
-//the extracted SessionID.
-func EndSession(r *http.Request, signingKey string, store Store) (SessionID, error) {
-	//TODO: get the SessionID from the request, and delete the
-	//data associated with it in the store.
-	sid, err := GetSessionID(r, signingKey)
+//the extracted SessionID. In ModeToken this revokes the refresh
+//token, and the access token remains valid until it expires.
+func EndSession(r *http.Request, cfg *Config, store Store) (SessionID, error) {
+	store = WithContext(store, r.Context())
+	var sid SessionID
+	var err error
+	if cfg.Mode == ModeToken {
+		sid, err = GetState(r, cfg, store, &json.RawMessage{})
+	} else {
+		sid, err = GetSessionID(r, cfg)
+	}
 	if err != nil {
 		return InvalidSessionID, ErrNoSessionID
 	}
-	id := strings.TrimPrefix(sid.String(), schemeBearer)
-	err = store.Delete(SessionID(id))
-	return SessionID(sid), err
+	err = store.Delete(sid)
+	return sid, err
+}
+
+//Refresh rotates the refresh token sent by the client. It populates
+//`sessionState` with the state saved for the token, calls `update`, which
+//may change the state, moves the state to a new refresh token, adds new
+//access and refresh tokens to the response, and returns the old and new
+//store keys. If `update` returns an error the token is revoked and the
+//error returned. Presenting a token that was already rotated revokes its
+//replacements and returns ErrRefreshReused. The token's state is taken from
+//the store atomically, so of concurrent refreshes with the same token only
+//one succeeds.
+func Refresh(r *http.Request, cfg *Config, store Store, sessionState interface{}, w http.ResponseWriter,
+	update func() error) (SessionID, SessionID, error) {
+	store = WithContext(store, r.Context())
+	token, err := getRefreshToken(r, cfg)
+	if err != nil {
+		return InvalidSessionID, InvalidSessionID, err
+	}
+	refresh, err := ValidateID(token, cfg.Keys)
+	if err != nil {
+		return InvalidSessionID, InvalidSessionID, err
+	}
+	oldKey := refresh.refreshKey()
+	if err := store.Take(oldKey, sessionState); err != nil {
+		rot := &rotation{}
+		if store.Get(oldKey.rotatedKey(), rot) == nil {
+			revokeRotations(store, rot.ReplacedBy)
+			return InvalidSessionID, InvalidSessionID, ErrRefreshReused
+		}
+		return InvalidSessionID, InvalidSessionID, ErrStateNotFound
+	}
+	if err := update(); err != nil {
+		return InvalidSessionID, InvalidSessionID, err
+	}
+
+	newKey, err := beginTokenSession(cfg, store, sessionState, w)
+	if err != nil {
+		return InvalidSessionID, InvalidSessionID, err
+	}
+	if err := store.Save(oldKey.rotatedKey(), &rotation{ReplacedBy: newKey}); err != nil {
+		return InvalidSessionID, InvalidSessionID, err
+	}
+	return oldKey, newKey, nil
+}
+
+//revokeRotations deletes the refresh token stored under `key`
+//and every token it was rotated into
+func revokeRotations(store Store, key SessionID) {
+	for key != InvalidSessionID {
+		store.Delete(key)
+		rot := &rotation{}
+		if err := store.Get(key.rotatedKey(), rot); err != nil {
+			return
+		}
+		key = rot.ReplacedBy
+	}
 }
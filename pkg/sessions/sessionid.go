@@ -14,65 +14,110 @@ const InvalidSessionID SessionID = ""
 //idLength is the length of the ID portion
 const idLength = 32
 
-//signedLength is the full length of the signed session ID
-//(ID portion plus signature)
+//signedLength is the full length of a legacy signed session ID
+//(ID portion plus signature), issued before key IDs were added
 const signedLength = idLength + sha256.Size
 
 //SessionID represents a valid, digitally-signed session ID.
 //This is a base64 URL encoded string created from a byte slice
-//where the first `idLength` bytes are crytographically random
-//bytes representing the unique session ID, and the remaining bytes
-//are an HMAC hash of those ID bytes (i.e., a digital signature).
+//that starts with the ID of the signing key, followed by `idLength`
+//crytographically random bytes representing the unique session ID,
+//and ends with an HMAC hash of the preceding bytes (i.e., a digital signature).
 //The byte slice layout is like so:
-//+-----------------------------------------------------+
-//|...32 crypto random bytes...|HMAC hash of those bytes|
-//+-----------------------------------------------------+
+//+-----------------------------------------------------------------------------+
+//|key ID length (1 byte)|key ID|...32 crypto random bytes...|HMAC hash of those|
+//+-----------------------------------------------------------------------------+
+//Legacy session IDs have no key ID prefix and are accepted with any active key.
 type SessionID string
 
 //ErrInvalidID is returned when an invalid session id is passed to ValidateID()
 var ErrInvalidID = errors.New("Invalid Session ID")
 
 //NewSessionID creates and returns a new digitally-signed session ID,
-//using `signingKey` as the HMAC signing key. An error is returned only
-//if there was an error generating random bytes for the session ID
-func NewSessionID(signingKey string) (SessionID, error) {
-	if signingKey == "" {
+//using the current key in `keys` as the HMAC signing key. An error is
+//returned only if there was an error generating random bytes for the session ID
+func NewSessionID(keys *KeyRing) (SessionID, error) {
+	if keys == nil || keys.Current() == nil {
 		return InvalidSessionID, ErrInvalidID
 	}
+	key := keys.Current()
 	randomBytes := make([]byte, idLength)
 	_, err := rand.Read(randomBytes)
 	if err != nil {
 		return InvalidSessionID, err
 	}
 
-	key := []byte(signingKey)
-	h := hmac.New(sha256.New, key)
-	h.Write(randomBytes)
+	id := append([]byte{byte(len(key.ID))}, key.ID...)
+	id = append(id, randomBytes...)
+	h := hmac.New(sha256.New, key.Secret)
+	h.Write(id)
 	signature := h.Sum(nil)
 
-	id := append(randomBytes, signature...)
+	id = append(id, signature...)
 	sessionID := base64.URLEncoding.EncodeToString(id)
 	return SessionID(sessionID), nil
 }
 
 //ValidateID validates the string in the `id` parameter
-//using the `signingKey` as the HMAC signing key
+//using the key it was signed with from `keys`
 //and returns an error if invalid, or a SessionID if valid
-func ValidateID(id string, signingKey string) (SessionID, error) {
+func ValidateID(id string, keys *KeyRing) (SessionID, error) {
 	decodedID, err := base64.URLEncoding.DecodeString(id)
 	if err != nil {
 		return InvalidSessionID, err
 	}
-	h := hmac.New(sha256.New, []byte(signingKey))
-	h.Write(decodedID[:idLength])
-	hash := h.Sum(nil)
-	if !hmac.Equal(hash, decodedID[idLength:]) {
+	if len(decodedID) == signedLength {
+		for _, key := range keys.Active() {
+			if validSignature(decodedID, idLength, key) {
+				return SessionID(id), nil
+			}
+		}
+		return InvalidSessionID, ErrInvalidID
+	}
+
+	if len(decodedID) == 0 {
+		return InvalidSessionID, ErrInvalidID
+	}
+	kidLength := int(decodedID[0])
+	signedPortion := 1 + kidLength + idLength
+	if len(decodedID) != signedPortion+sha256.Size {
+		return InvalidSessionID, ErrInvalidID
+	}
+	key, found := keys.Get(string(decodedID[1 : 1+kidLength]))
+	if !found || !validSignature(decodedID, signedPortion, key) {
 		return InvalidSessionID, ErrInvalidID
 	}
 	return SessionID(id), nil
 }
 
+//validSignature returns true if the bytes after `signedPortion` are the
+//HMAC hash of the bytes before it, using the given key
+func validSignature(decodedID []byte, signedPortion int, key *Key) bool {
+	h := hmac.New(sha256.New, key.Secret)
+	h.Write(decodedID[:signedPortion])
+	return hmac.Equal(h.Sum(nil), decodedID[signedPortion:])
+}
+
+//Handle returns a short identifier for the SessionID that is safe
+//to show to clients, since the SessionID can't be derived from it
+func (sid SessionID) Handle() string {
+	hash := sha256.Sum256([]byte(sid))
+	return base64.RawURLEncoding.EncodeToString(hash[:12])
+}
+
 //String returns a string representation of the sessionID
 func (sid SessionID) String() string {
 	return string(sid)
 }
+
+//refreshKey returns the key a refresh token's state is stored under.
+//Only the token's Handle is stored, so the store never holds usable tokens.
+func (sid SessionID) refreshKey() SessionID {
+	return SessionID(sid.Handle())
+}
+
+//rotatedKey returns the key recording which refresh token replaced
+//the one stored under `sid`
+func (sid SessionID) rotatedKey() SessionID {
+	return SessionID(sid.String() + ".rotated")
+}
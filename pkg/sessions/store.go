@@ -25,4 +25,20 @@ type Store interface {
 
 	//Delete deletes all state data associated with the SessionID from the store.
 	Delete(sid SessionID) error
+
+	//Take populates `sessionState` with the data saved for the given
+	//SessionID and deletes it, atomically, so when the same SessionID is
+	//taken concurrently only one Take succeeds. The rest get ErrStateNotFound.
+	Take(sid SessionID, sessionState interface{}) error
+
+	//AddToUser records the SessionID in the index of sessions
+	//belonging to the given user ID
+	AddToUser(userID int64, sid SessionID) error
+
+	//GetUserSessions returns the SessionIDs in the given user's index
+	//that still have state in the store
+	GetUserSessions(userID int64) ([]SessionID, error)
+
+	//RemoveFromUser removes the SessionID from the given user's index
+	RemoveFromUser(userID int64, sid SessionID) error
 }
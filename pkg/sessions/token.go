@@ -0,0 +1,155 @@
+package sessions
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+//AlgHS256 signs access tokens with HMAC-SHA256 using the session key ring
+const AlgHS256 = "HS256"
+
+//AlgEdDSA signs access tokens with an Ed25519 private key
+const AlgEdDSA = "EdDSA"
+
+//ErrInvalidToken is returned when an access token is malformed,
+//has an invalid signature or has expired
+var ErrInvalidToken = errors.New("invalid access token")
+
+//tokenHeader is the JOSE header of an access token
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+//tokenClaims are the claims carried by an access token. `State` holds
+//the session state so it can be read without a session store lookup.
+type tokenClaims struct {
+	IssuedAt  int64           `json:"iat"`
+	ExpiresAt int64           `json:"exp"`
+	SessionID SessionID       `json:"sid"`
+	State     json.RawMessage `json:"state"`
+}
+
+var tokenEncoding = base64.RawURLEncoding
+
+//NewAccessToken returns a signed access token carrying `sessionState`
+//for the session stored under `sid`, which expires after the
+//configured AccessTokenDuration
+func NewAccessToken(cfg *Config, sid SessionID, sessionState interface{}) (string, error) {
+	state, err := json.Marshal(sessionState)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	header := &tokenHeader{Alg: cfg.Algorithm, Typ: "JWT"}
+	if cfg.Algorithm == AlgHS256 {
+		header.Kid = cfg.Keys.Current().ID
+	}
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(&tokenClaims{
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(cfg.AccessTokenDuration).Unix(),
+		SessionID: sid,
+		State:     state,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := tokenEncoding.EncodeToString(headerBytes) + "." + tokenEncoding.EncodeToString(claims)
+	signature, err := signToken(cfg, header, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + tokenEncoding.EncodeToString(signature), nil
+}
+
+//ParseAccessToken verifies the access token, populates `sessionState`
+//with the state it carries and returns the SessionID it was issued for
+func ParseAccessToken(cfg *Config, token string, sessionState interface{}) (SessionID, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return InvalidSessionID, ErrInvalidToken
+	}
+	headerBytes, err := tokenEncoding.DecodeString(parts[0])
+	if err != nil {
+		return InvalidSessionID, ErrInvalidToken
+	}
+	header := &tokenHeader{}
+	if err := json.Unmarshal(headerBytes, header); err != nil || header.Alg != cfg.Algorithm {
+		return InvalidSessionID, ErrInvalidToken
+	}
+	signature, err := tokenEncoding.DecodeString(parts[2])
+	if err != nil {
+		return InvalidSessionID, ErrInvalidToken
+	}
+	if !verifyToken(cfg, header, []byte(parts[0]+"."+parts[1]), signature) {
+		return InvalidSessionID, ErrInvalidToken
+	}
+	claimBytes, err := tokenEncoding.DecodeString(parts[1])
+	if err != nil {
+		return InvalidSessionID, ErrInvalidToken
+	}
+	claims := &tokenClaims{}
+	if err := json.Unmarshal(claimBytes, claims); err != nil {
+		return InvalidSessionID, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return InvalidSessionID, ErrInvalidToken
+	}
+	if err := json.Unmarshal(claims.State, sessionState); err != nil {
+		return InvalidSessionID, ErrInvalidToken
+	}
+	return claims.SessionID, nil
+}
+
+//signToken signs the token's signing input with the configured algorithm,
+//using the key named in the header for HS256
+func signToken(cfg *Config, header *tokenHeader, signingInput []byte) ([]byte, error) {
+	switch cfg.Algorithm {
+	case AlgHS256:
+		key, found := cfg.Keys.Get(header.Kid)
+		if !found {
+			return nil, ErrNoCurrentKey
+		}
+		h := hmac.New(sha256.New, key.Secret)
+		h.Write(signingInput)
+		return h.Sum(nil), nil
+	case AlgEdDSA:
+		if len(cfg.EdDSAKey) != ed25519.PrivateKeySize {
+			return nil, ErrInvalidToken
+		}
+		return ed25519.Sign(cfg.EdDSAKey, signingInput), nil
+	}
+	return nil, ErrInvalidToken
+}
+
+//verifyToken checks the signature over the token's signing input,
+//using the key named in the header for HS256
+func verifyToken(cfg *Config, header *tokenHeader, signingInput []byte, signature []byte) bool {
+	switch cfg.Algorithm {
+	case AlgHS256:
+		key, found := cfg.Keys.Get(header.Kid)
+		if !found {
+			return false
+		}
+		h := hmac.New(sha256.New, key.Secret)
+		h.Write(signingInput)
+		return hmac.Equal(h.Sum(nil), signature)
+	case AlgEdDSA:
+		if len(cfg.EdDSAKey) != ed25519.PrivateKeySize {
+			return false
+		}
+		return ed25519.Verify(cfg.EdDSAKey.Public().(ed25519.PublicKey), signingInput, signature)
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+//Transport selects how session credentials travel between client and server
+type Transport int
+
+const (
+	//TransportHeader sends credentials in the Authorization
+	//and Refresh-Token headers
+	TransportHeader Transport = iota
+	//TransportCookie sends credentials in Secure, HttpOnly cookies and
+	//requires a double-submit CSRF token on unsafe methods
+	TransportCookie
+)
+
+const cookieSession = "spectrum_session"
+const cookieRefresh = "spectrum_refresh"
+const cookieCSRF = "spectrum_csrf"
+
+//HeaderCSRF is the request header that must echo the CSRF cookie on
+//unsafe methods, and the response header the CSRF token is issued in
+const HeaderCSRF = "X-CSRF-Token"
+
+//refreshCookiePath limits the refresh cookie to the refresh endpoint
+const refreshCookiePath = "/v1/sessions/refresh"
+
+//csrfTokenLength is the number of random bytes in a CSRF token
+const csrfTokenLength = 32
+
+//ErrInvalidCSRF is returned when an unsafe request's CSRF header
+//doesn't match its CSRF cookie
+var ErrInvalidCSRF = errors.New("missing or invalid CSRF token")
+
+//setCredentials adds the session credential, and the refresh token if
+//not empty, to the response using the configured transport
+func setCredentials(w http.ResponseWriter, cfg *Config, credential string, refresh string) error {
+	if cfg.Transport != TransportCookie {
+		w.Header().Add(headerAuthorization, schemeBearer+credential)
+		if refresh != "" {
+			w.Header().Add(headerRefreshToken, refresh)
+		}
+		return nil
+	}
+
+	csrf := make([]byte, csrfTokenLength)
+	if _, err := rand.Read(csrf); err != nil {
+		return err
+	}
+	csrfToken := base64.RawURLEncoding.EncodeToString(csrf)
+	http.SetCookie(w, newCookie(cfg, cookieSession, credential, "/", true))
+	http.SetCookie(w, newCookie(cfg, cookieCSRF, csrfToken, "/", false))
+	if refresh != "" {
+		http.SetCookie(w, newCookie(cfg, cookieRefresh, refresh, refreshCookiePath, true))
+	}
+	w.Header().Set(HeaderCSRF, csrfToken)
+	return nil
+}
+
+//ClearCredentials expires the session cookies on the client when the
+//cookie transport is configured. Header credentials are simply discarded
+//by the client.
+func ClearCredentials(w http.ResponseWriter, cfg *Config) {
+	if cfg.Transport != TransportCookie {
+		return
+	}
+	for _, cookie := range []*http.Cookie{
+		newCookie(cfg, cookieSession, "", "/", true),
+		newCookie(cfg, cookieCSRF, "", "/", false),
+		newCookie(cfg, cookieRefresh, "", refreshCookiePath, true),
+	} {
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+	}
+}
+
+//newCookie returns a Secure cookie with the configured domain and SameSite mode
+func newCookie(cfg *Config, name string, value string, path string, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.CookieDomain,
+		Secure:   true,
+		HttpOnly: httpOnly,
+		SameSite: cfg.SameSite,
+	}
+}
+
+//getCredential returns the session credential from the request using the
+//configured transport. The "auth" query string parameter is only accepted
+//for WebSocket upgrades, since browsers can't set headers on them.
+func getCredential(r *http.Request, cfg *Config) (string, error) {
+	if cfg.Transport == TransportCookie {
+		cookie, err := r.Cookie(cookieSession)
+		if err != nil || cookie.Value == "" {
+			return "", ErrNoSessionID
+		}
+		if err := checkCSRF(r); err != nil {
+			return "", err
+		}
+		return cookie.Value, nil
+	}
+
+	token := r.Header.Get(headerAuthorization)
+	if token == "" && isWebSocketUpgrade(r) {
+		token = r.URL.Query().Get(paramAuthorization)
+	}
+	if token == "" {
+		return "", ErrNoSessionID
+	}
+	if !strings.HasPrefix(token, schemeBearer) {
+		return "", ErrInvalidScheme
+	}
+	return strings.TrimPrefix(token, schemeBearer), nil
+}
+
+//getRefreshToken returns the refresh token from the request using the
+//configured transport
+func getRefreshToken(r *http.Request, cfg *Config) (string, error) {
+	if cfg.Transport == TransportCookie {
+		cookie, err := r.Cookie(cookieRefresh)
+		if err != nil || cookie.Value == "" {
+			return "", ErrNoRefreshToken
+		}
+		if err := checkCSRF(r); err != nil {
+			return "", err
+		}
+		return cookie.Value, nil
+	}
+	token := r.Header.Get(headerRefreshToken)
+	if token == "" {
+		return "", ErrNoRefreshToken
+	}
+	return token, nil
+}
+
+//checkCSRF requires unsafe requests to echo the CSRF cookie in the CSRF header
+func checkCSRF(r *http.Request) error {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return nil
+	}
+	cookie, err := r.Cookie(cookieCSRF)
+	if err != nil || cookie.Value == "" {
+		return ErrInvalidCSRF
+	}
+	header := r.Header.Get(HeaderCSRF)
+	if subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return ErrInvalidCSRF
+	}
+	return nil
+}
+
+//isWebSocketUpgrade returns true if the request asks to upgrade to a WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
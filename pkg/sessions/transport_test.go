@@ -0,0 +1,99 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//beginCookieSession begins a session with the cookie transport and
+//returns the config, store and response that issued it
+func beginCookieSession(t *testing.T) (*Config, Store, *httptest.ResponseRecorder) {
+	keys, err := ParseKeyRing("k1:first")
+	if err != nil {
+		t.Fatalf("ParseKeyRing: unexpected error %v", err)
+	}
+	cfg := &Config{Keys: keys, Transport: TransportCookie, SameSite: http.SameSiteLaxMode}
+	store := NewMemStore(time.Hour, time.Hour)
+	w := httptest.NewRecorder()
+	if _, err := BeginSession(cfg, store, &map[string]string{"user": "1"}, w); err != nil {
+		t.Fatalf("BeginSession: unexpected error %v", err)
+	}
+	return cfg, store, w
+}
+
+//requestWithCookies returns a request carrying the cookies set on `w`
+func requestWithCookies(method string, w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest(method, "/v1/users/me", nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+func TestCookieCredentials(t *testing.T) {
+	_, _, w := beginCookieSession(t)
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	session, csrf := cookies[cookieSession], cookies[cookieCSRF]
+	if session == nil || !session.HttpOnly || !session.Secure {
+		t.Errorf("session cookie: got %v, want a Secure, HttpOnly cookie", session)
+	}
+	if csrf == nil || csrf.HttpOnly || !csrf.Secure {
+		t.Fatalf("CSRF cookie: got %v, want a Secure cookie readable by scripts", csrf)
+	}
+	if w.Header().Get(HeaderCSRF) != csrf.Value {
+		t.Errorf("CSRF header: got %q, want the CSRF cookie's value", w.Header().Get(HeaderCSRF))
+	}
+	if w.Header().Get(headerAuthorization) != "" {
+		t.Error("Authorization header: set with the cookie transport")
+	}
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	cfg, store, w := beginCookieSession(t)
+	token := w.Header().Get(HeaderCSRF)
+	cases := []struct {
+		name   string
+		method string
+		header string
+		err    error
+	}{
+		{"safe method without header", "GET", "", nil},
+		{"unsafe method with matching header", "POST", token, nil},
+		{"unsafe method without header", "POST", "", ErrInvalidCSRF},
+		{"unsafe method with wrong header", "DELETE", token + "x", ErrInvalidCSRF},
+		{"unsafe method with another session's header", "PATCH", "c29tZSBvdGhlciB0b2tlbg", ErrInvalidCSRF},
+	}
+	for _, c := range cases {
+		r := requestWithCookies(c.method, w)
+		if c.header != "" {
+			r.Header.Set(HeaderCSRF, c.header)
+		}
+		state := map[string]string{}
+		_, err := GetState(r, cfg, store, &state)
+		if err != c.err {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.err)
+		}
+		if err == nil && state["user"] != "1" {
+			t.Errorf("%s: got state %v, want the session's state", c.name, state)
+		}
+	}
+}
+
+func TestCSRFRequiresCookie(t *testing.T) {
+	cfg, store, w := beginCookieSession(t)
+	r := httptest.NewRequest("POST", "/v1/users/me", nil)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name != cookieCSRF {
+			r.AddCookie(cookie)
+		}
+	}
+	r.Header.Set(HeaderCSRF, w.Header().Get(HeaderCSRF))
+	if _, err := GetState(r, cfg, store, &map[string]string{}); err != ErrInvalidCSRF {
+		t.Errorf("header without the CSRF cookie: got %v, want ErrInvalidCSRF", err)
+	}
+}
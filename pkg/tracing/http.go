@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/2charm/spectrum-api/pkg/util"
+)
+
+//HTTPTracing starts a server span for each request to `handler`, continuing
+//the trace in the request's traceparent header if it has one. Spans are
+//named after the pattern of the `routes` handler the request matches.
+type HTTPTracing struct {
+	handler http.Handler
+	routes  *http.ServeMux
+}
+
+//NewHTTPTracing constructs an HTTPTracing. `handler` is usually `routes`,
+//or middleware wrapping it.
+func NewHTTPTracing(handler http.Handler, routes *http.ServeMux) *HTTPTracing {
+	return &HTTPTracing{handler: handler, routes: routes}
+}
+
+func (ht *HTTPTracing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, route := ht.routes.Handler(r)
+	if route == "" {
+		route = "unmatched"
+	}
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, r.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(r.URL.Path),
+		))
+	defer span.End()
+
+	recorder := util.NewStatusRecorder(w)
+	ht.handler.ServeHTTP(recorder, r.WithContext(ctx))
+	span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.Status))
+	if recorder.Status >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(recorder.Status))
+	}
+}
+
+//Inject adds the trace context in `ctx` to the headers as traceparent,
+//so the service receiving the request continues the trace
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+//DB is a *sql.DB that records a span for each query, as a child of the
+//span in the context given to WithContext. Its Query, QueryRow and Exec
+//have the signatures of *sql.DB's, so stores can use either.
+type DB struct {
+	*sql.DB
+	ctx context.Context
+}
+
+//NewDB wraps `db`
+func NewDB(db *sql.DB) *DB {
+	return &DB{DB: db, ctx: context.Background()}
+}
+
+//WithContext returns a copy of the DB whose queries are part of `ctx`
+func (db *DB) WithContext(ctx context.Context) *DB {
+	return &DB{DB: db.DB, ctx: ctx}
+}
+
+//startQuery starts a span for the query, named after its operation
+func (db *DB) startQuery(query string) (context.Context, func(error)) {
+	operation := strings.ToUpper(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+	ctx, span := Start(db.ctx, "sql "+operation,
+		semconv.DBSystemMySQL,
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(query),
+	)
+	return ctx, func(err error) { End(span, err) }
+}
+
+//Query executes a query that returns rows
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, end := db.startQuery(query)
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	end(err)
+	return rows, err
+}
+
+//QueryRow executes a query that returns at most one row
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	ctx, end := db.startQuery(query)
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	end(row.Err())
+	return row
+}
+
+//Exec executes a query that doesn't return rows
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, end := db.startQuery(query)
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	end(err)
+	return res, err
+}
+
+//Begin starts a transaction as part of the DB's context
+func (db *DB) Begin() (*sql.Tx, error) {
+	return db.DB.BeginTx(db.ctx, nil)
+}
@@ -0,0 +1,182 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+//ErrCircuitOpen is returned instead of calling an upstream whose breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+//OpenError is returned by Breaker.Allow while the breaker is open.
+//It matches ErrCircuitOpen with errors.Is.
+type OpenError struct {
+	Name string
+	//RetryAfter is how long until the breaker lets a trial call through
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, ErrCircuitOpen)
+}
+
+//Is reports whether the target is ErrCircuitOpen
+func (e *OpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+//State is the state of a Breaker
+type State int
+
+const (
+	//StateClosed lets every call through
+	StateClosed State = iota
+	//StateOpen fails every call until the cooldown has passed
+	StateOpen
+	//StateHalfOpen lets a single trial call through, which closes
+	//the breaker if it succeeds and opens it again if it fails
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+//Breaker is a circuit breaker for one upstream endpoint. It opens after
+//Threshold consecutive failures, so calls fail fast instead of piling up
+//on an upstream that's down, and tries again once Cooldown has passed.
+type Breaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+
+	mx       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	//trial is true while the half-open trial call is in flight
+	trial bool
+}
+
+//NewBreaker constructs a closed Breaker
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Name: name, Threshold: threshold, Cooldown: cooldown}
+}
+
+//Allow returns an *OpenError if the call must not be made. Every call
+//that's allowed must be followed by Success, Failure or Cancel.
+func (b *Breaker) Allow() error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	switch b.state {
+	case StateOpen:
+		if wait := b.Cooldown - time.Since(b.openedAt); wait > 0 {
+			return &OpenError{Name: b.Name, RetryAfter: wait}
+		}
+		b.setState(StateHalfOpen)
+		b.trial = true
+	case StateHalfOpen:
+		if b.trial {
+			return &OpenError{Name: b.Name, RetryAfter: b.Cooldown}
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+//Success records a successful call, closing the breaker
+func (b *Breaker) Success() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.state != StateClosed {
+		slog.Info("circuit breaker closed", "breaker", b.Name)
+	}
+	b.setState(StateClosed)
+	b.failures = 0
+	b.trial = false
+}
+
+//Failure records a failed call, opening the breaker if the trial call
+//failed or there have been Threshold failures in a row
+func (b *Breaker) Failure() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.trial = false
+	b.failures++
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.Threshold) {
+		slog.Warn("circuit breaker opened", "breaker", b.Name, "failures", b.failures)
+		b.setState(StateOpen)
+		b.openedAt = time.Now()
+	}
+}
+
+//Cancel releases a call that was allowed but abandoned before it said
+//anything about the upstream, such as one the client canceled. It counts
+//neither a success nor a failure, and lets another trial call through
+//while the breaker is half-open.
+func (b *Breaker) Cancel() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.trial = false
+}
+
+//setState changes the state and records it. The caller must hold b.mx.
+func (b *Breaker) setState(state State) {
+	b.state = state
+	breakerState.WithLabelValues(b.Name).Set(float64(state))
+}
+
+//State returns the breaker's current state
+func (b *Breaker) State() State {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.state
+}
+
+//Breakers holds a Breaker for each endpoint, created on first use
+type Breakers struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mx       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+//NewBreakers constructs Breakers that open after `threshold`
+//consecutive failures and stay open for `cooldown`
+func NewBreakers(threshold int, cooldown time.Duration) *Breakers {
+	return &Breakers{Threshold: threshold, Cooldown: cooldown, breakers: map[string]*Breaker{}}
+}
+
+//Get returns the breaker for the endpoint
+func (bs *Breakers) Get(name string) *Breaker {
+	bs.mx.Lock()
+	defer bs.mx.Unlock()
+	b, found := bs.breakers[name]
+	if !found {
+		b = NewBreaker(name, bs.Threshold, bs.Cooldown)
+		bs.breakers[name] = b
+	}
+	return b
+}
+
+//States returns the state of every endpoint's breaker
+func (bs *Breakers) States() map[string]string {
+	bs.mx.Lock()
+	defer bs.mx.Unlock()
+	states := map[string]string{}
+	for name, b := range bs.breakers {
+		states[name] = b.State().String()
+	}
+	return states
+}
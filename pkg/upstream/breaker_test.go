@@ -0,0 +1,97 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//roundTripper is an http.RoundTripper calling a function
+type roundTripper func(r *http.Request) (*http.Response, error)
+
+func (rt roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return rt(r)
+}
+
+//openBreaker returns a breaker that has opened and whose cooldown has
+//passed, so its next call is the half-open trial
+func openBreaker(t *testing.T) *Breaker {
+	b := NewBreaker("test", 1, time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow: unexpected error %v", err)
+	}
+	b.Failure()
+	if b.State() != StateOpen {
+		t.Fatalf("State: got %v, want open", b.State())
+	}
+	time.Sleep(2 * time.Millisecond)
+	return b
+}
+
+func TestBreakerTrial(t *testing.T) {
+	b := openBreaker(t)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("trial Allow: unexpected error %v", err)
+	}
+	if b.State() != StateHalfOpen {
+		t.Errorf("State: got %v, want half-open", b.State())
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Allow during the trial: got %v, want ErrCircuitOpen", err)
+	}
+	b.Success()
+	if b.State() != StateClosed {
+		t.Errorf("State after a successful trial: got %v, want closed", b.State())
+	}
+}
+
+func TestBreakerCanceledTrial(t *testing.T) {
+	b := openBreaker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := roundTripper(func(r *http.Request) (*http.Response, error) {
+		return nil, r.Context().Err()
+	})
+	r := httptest.NewRequest("GET", "http://news/v1/news", nil).WithContext(ctx)
+	if _, err := NewBreakerTransport(canceled, b).RoundTrip(r); err != context.Canceled {
+		t.Fatalf("RoundTrip: got %v, want context.Canceled", err)
+	}
+	if b.State() != StateHalfOpen {
+		t.Errorf("State after a canceled trial: got %v, want half-open", b.State())
+	}
+
+	//the canceled trial neither opened nor wedged the breaker
+	ok := roundTripper(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	r = httptest.NewRequest("GET", "http://news/v1/news", nil)
+	if _, err := NewBreakerTransport(ok, b).RoundTrip(r); err != nil {
+		t.Fatalf("RoundTrip after a canceled trial: unexpected error %v", err)
+	}
+	if b.State() != StateClosed {
+		t.Errorf("State after the next trial succeeded: got %v, want closed", b.State())
+	}
+}
+
+func TestClientRejectedAdmitReleasesTrial(t *testing.T) {
+	client := NewClient(time.Second)
+	client.Breakers = NewBreakers(1, time.Millisecond)
+	b := client.Breakers.Get("top")
+	b.Allow()
+	b.Failure()
+	time.Sleep(2 * time.Millisecond)
+
+	exhausted := errors.New("quota exhausted")
+	_, err := client.Get(context.Background(), "top", "http://newsapi.invalid/", nil, func() error {
+		return exhausted
+	})
+	if err != exhausted {
+		t.Fatalf("Get: got %v, want the admit error", err)
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("Allow after the trial wasn't admitted: got %v, want a new trial", err)
+	}
+}
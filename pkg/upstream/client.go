@@ -0,0 +1,152 @@
+package upstream
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/2charm/spectrum-api/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+//Client makes GET requests to an upstream API, retrying failures with
+//jittered exponential backoff and failing fast while an endpoint's
+//breaker is open
+type Client struct {
+	HTTPClient *http.Client
+	Breakers   *Breakers
+	//MaxRetries is the number of times a failed request is retried
+	MaxRetries int
+	//BaseDelay is the backoff before the first retry, doubled for each
+	//retry after it, and MaxDelay caps it. A Retry-After longer than
+	//MaxDelay isn't waited for.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	//Retryable returns true for the responses worth retrying. It
+	//defaults to 429s and 5xxs. It may read the body if it replaces it.
+	Retryable func(resp *http.Response) bool
+}
+
+//NewClient constructs a Client whose requests time out after `timeout`,
+//with 2 retries and breakers that open for 30 seconds after 5 failures
+func NewClient(timeout time.Duration) *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: timeout},
+		Breakers:   NewBreakers(5, time.Second*30),
+		MaxRetries: 2,
+		BaseDelay:  time.Millisecond * 500,
+		MaxDelay:   time.Second * 10,
+	}
+}
+
+//retryable returns true for the status codes worth retrying
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+//retry returns true if the response is worth retrying
+func (c *Client) retry(resp *http.Response) bool {
+	if c.Retryable != nil {
+		return c.Retryable(resp)
+	}
+	return retryable(resp.StatusCode)
+}
+
+//Get requests `url` with the headers in `header`, counting failures
+//against the endpoint's breaker. Network errors and retryable responses
+//are retried. If every attempt fails the last response is returned, so
+//the caller can read its error body. `admit`, if not nil, is called before
+//each attempt, and its error is returned instead of making the attempt,
+//so a quota can count every request made. Each attempt is traced as a
+//child of the span in `ctx`. Credentials belong in `header`, as the URL
+//shows up in the errors of failed requests.
+func (c *Client) Get(ctx context.Context, endpoint string, url string, header http.Header, admit func() error) (*http.Response, error) {
+	breaker := c.Breakers.Get(endpoint)
+	for attempt := 0; ; attempt++ {
+		if err := breaker.Allow(); err != nil {
+			return nil, err
+		}
+		if admit != nil {
+			if err := admit(); err != nil {
+				breaker.Cancel()
+				return nil, err
+			}
+		}
+		start := time.Now()
+		resp, err := c.attempt(ctx, endpoint, url, header, attempt)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		attemptDuration.WithLabelValues(endpoint, code).Observe(time.Since(start).Seconds())
+		if err == nil && !c.retry(resp) {
+			breaker.Success()
+			return resp, nil
+		}
+		breaker.Failure()
+		if attempt >= c.MaxRetries {
+			return resp, err
+		}
+		delay := c.backoff(attempt)
+		if resp != nil {
+			if wait, ok := ParseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if wait > c.MaxDelay {
+					return resp, nil
+				}
+				delay = wait
+			}
+			resp.Body.Close()
+		}
+		time.Sleep(delay)
+	}
+}
+
+//attempt makes a single request for Get, in a span named after the
+//endpoint. The request isn't canceled with `ctx`, as its response may be
+//cached for other clients.
+func (c *Client) attempt(ctx context.Context, endpoint string, url string, header http.Header, attempt int) (*http.Response, error) {
+	ctx, span := tracing.Start(ctx, "GET "+endpoint, attribute.Int("upstream.attempt", attempt))
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodGet, url, nil)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+//backoff returns a random delay of up to BaseDelay * 2^attempt, capped at MaxDelay
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.BaseDelay << uint(attempt)
+	if ceiling > c.MaxDelay || ceiling <= 0 {
+		ceiling = c.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+//ParseRetryAfter parses a Retry-After header, given either in seconds or as an HTTP date
+func ParseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
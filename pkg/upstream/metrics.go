@@ -0,0 +1,19 @@
+package upstream
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var attemptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spectrum",
+	Name:      "upstream_attempt_duration_seconds",
+	Help:      "Time taken by each attempt of an upstream request, retries included, by endpoint and status.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"endpoint", "code"})
+
+var breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "spectrum",
+	Name:      "upstream_breaker_state",
+	Help:      "State of each circuit breaker: 0 closed, 1 open, 2 half-open.",
+}, []string{"name"})
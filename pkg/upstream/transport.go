@@ -0,0 +1,40 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+)
+
+//BreakerTransport is an http.RoundTripper that counts errors and 500
+//and 504 responses against its breaker, and fails fast while the breaker
+//is open. 502 and 503 are left out since services behind the gateway use
+//them to report the failures of their own upstreams, and requests the
+//client canceled are left out since they say nothing about the upstream.
+type BreakerTransport struct {
+	Transport http.RoundTripper
+	Breaker   *Breaker
+}
+
+//NewBreakerTransport wraps `transport` with `breaker`
+func NewBreakerTransport(transport http.RoundTripper, breaker *Breaker) *BreakerTransport {
+	return &BreakerTransport{Transport: transport, Breaker: breaker}
+}
+
+//RoundTrip sends the request if the breaker allows it
+func (bt *BreakerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := bt.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+	resp, err := bt.Transport.RoundTrip(r)
+	if err != nil && r.Context().Err() == context.Canceled {
+		bt.Breaker.Cancel()
+		return resp, err
+	}
+	if err != nil || resp.StatusCode == http.StatusInternalServerError ||
+		resp.StatusCode == http.StatusGatewayTimeout {
+		bt.Breaker.Failure()
+	} else {
+		bt.Breaker.Success()
+	}
+	return resp, err
+}
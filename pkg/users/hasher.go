@@ -0,0 +1,248 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//ErrMismatchedPassword is returned when a password doesn't match its hash
+var ErrMismatchedPassword = errors.New("password does not match hash")
+
+//ErrUnknownHashAlgorithm is returned when a stored hash wasn't made by any known Hasher
+var ErrUnknownHashAlgorithm = errors.New("unknown password hash algorithm")
+
+//ErrInvalidHash is returned when a stored hash can't be parsed
+var ErrInvalidHash = errors.New("invalid password hash")
+
+//Hasher hashes and verifies passwords. Hashes are self-describing
+//strings that start with the algorithm identifier, such as
+//"$argon2id$" in the PHC string format or "$2a$" for bcrypt.
+type Hasher interface {
+	//Hash returns a new hash of the password
+	Hash(password string) ([]byte, error)
+	//Verify returns nil if the password matches the hash
+	Verify(hash []byte, password string) error
+	//Identifies returns true if the hash was made by this Hasher
+	Identifies(hash []byte) bool
+	//NeedsRehash returns true if the hash was made with parameters
+	//other than the Hasher's current ones
+	NeedsRehash(hash []byte) bool
+}
+
+//Argon2idHasher hashes passwords with Argon2id, encoding
+//hashes in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+type Argon2idHasher struct {
+	//Time is the number of passes over the memory
+	Time uint32
+	//Memory is the memory used in KiB
+	Memory uint32
+	//Threads is the degree of parallelism
+	Threads uint8
+	//SaltLength and KeyLength are in bytes
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+const argon2idPrefix = "$argon2id$"
+
+var phcEncoding = base64.RawStdEncoding
+
+//NewArgon2idHasher returns an Argon2idHasher with parameters suited to
+//small containers: 19 MiB of memory, 2 passes and 1 thread
+func NewArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{
+		Time:       2,
+		Memory:     19 * 1024,
+		Threads:    1,
+		SaltLength: 16,
+		KeyLength:  32,
+	}
+}
+
+//Hash returns a PHC string of the Argon2id hash of the password
+func (ah *Argon2idHasher) Hash(password string) ([]byte, error) {
+	salt := make([]byte, ah.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey([]byte(password), salt, ah.Time, ah.Memory, ah.Threads, ah.KeyLength)
+	return []byte(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		ah.Memory, ah.Time, ah.Threads, phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key))), nil
+}
+
+//Verify hashes the password with the salt and parameters
+//stored in the hash and compares the result
+func (ah *Argon2idHasher) Verify(hash []byte, password string) error {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatchedPassword
+	}
+	return nil
+}
+
+//Identifies returns true if the hash is an Argon2id PHC string
+func (ah *Argon2idHasher) Identifies(hash []byte) bool {
+	return strings.HasPrefix(string(hash), argon2idPrefix)
+}
+
+//NeedsRehash returns true if the hash's parameters differ from the Hasher's
+func (ah *Argon2idHasher) NeedsRehash(hash []byte) bool {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Time != ah.Time || params.Memory != ah.Memory || params.Threads != ah.Threads ||
+		uint32(len(salt)) != ah.SaltLength || uint32(len(key)) != ah.KeyLength
+}
+
+//Bounds on the parameters of stored Argon2id hashes. argon2.IDKey panics
+//with no passes or threads, and a huge memory cost in a stored hash would
+//exhaust memory at sign-in.
+const (
+	maxArgon2Memory  = 256 * 1024 //KiB
+	maxArgon2Time    = 16
+	maxArgon2Threads = 16
+	minArgon2Salt    = 8
+	minArgon2Key     = 16
+	maxArgon2Key     = 64
+)
+
+//parseArgon2id parses an Argon2id PHC string into its parameters, salt and
+//key, and returns ErrInvalidHash if a parameter is out of bounds
+func parseArgon2id(hash []byte) (*Argon2idHasher, []byte, []byte, error) {
+	//"", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	fields := strings.Split(string(hash), "$")
+	if len(fields) != 6 || fields[1] != "argon2id" {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(fields[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	params := &Argon2idHasher{}
+	if _, err := fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	if params.Time < 1 || params.Time > maxArgon2Time || params.Threads < 1 || params.Threads > maxArgon2Threads ||
+		params.Memory < 8*uint32(params.Threads) || params.Memory > maxArgon2Memory {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	salt, err := phcEncoding.DecodeString(fields[4])
+	if err != nil || len(salt) < minArgon2Salt {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	key, err := phcEncoding.DecodeString(fields[5])
+	if err != nil || len(key) < minArgon2Key || len(key) > maxArgon2Key {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	return params, salt, key, nil
+}
+
+//BcryptHasher hashes passwords with bcrypt at the given cost
+type BcryptHasher struct {
+	Cost int
+}
+
+//Hash returns the bcrypt hash of the password
+func (bh *BcryptHasher) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), bh.Cost)
+}
+
+//Verify compares the password against the bcrypt hash
+func (bh *BcryptHasher) Verify(hash []byte, password string) error {
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return ErrMismatchedPassword
+		}
+		return err
+	}
+	return nil
+}
+
+//Identifies returns true if the hash is in the bcrypt
+//"$2a$", "$2b$" or "$2y$" format
+func (bh *BcryptHasher) Identifies(hash []byte) bool {
+	s := string(hash)
+	return strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") || strings.HasPrefix(s, "$2y$")
+}
+
+//NeedsRehash returns true if the hash's cost differs from the Hasher's
+func (bh *BcryptHasher) NeedsRehash(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost != bh.Cost
+}
+
+//bcryptHasher verifies hashes made before Argon2id became the default
+var bcryptHasher = &BcryptHasher{Cost: 13}
+
+//argon2idHasher is the default Hasher
+var argon2idHasher = NewArgon2idHasher()
+
+//passwordHasher hashes new passwords
+var passwordHasher Hasher = argon2idHasher
+
+//SetPasswordHasher sets the Hasher used to hash new passwords.
+//Hashes made by other Hashers still verify, and are rehashed
+//with this Hasher when their users sign in.
+func SetPasswordHasher(hasher Hasher) {
+	passwordHasher = hasher
+}
+
+//SetBcryptCost sets the cost of the bcrypt Hasher
+func SetBcryptCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	bcryptHasher.Cost = cost
+	return nil
+}
+
+//PasswordHasher returns the Hasher named "argon2id" or "bcrypt"
+func PasswordHasher(name string) (Hasher, error) {
+	switch name {
+	case "argon2id":
+		return argon2idHasher, nil
+	case "bcrypt":
+		return bcryptHasher, nil
+	}
+	return nil, fmt.Errorf("unknown password hasher %s, must be argon2id or bcrypt", name)
+}
+
+//hasherFor returns the Hasher that made the hash
+func hasherFor(hash []byte) (Hasher, error) {
+	for _, hasher := range []Hasher{passwordHasher, argon2idHasher, bcryptHasher} {
+		if hasher.Identifies(hash) {
+			return hasher, nil
+		}
+	}
+	return nil, ErrUnknownHashAlgorithm
+}
+
+//dummyHash is verified against by VerifyDummyHash
+var dummyHash []byte
+var dummyHashOnce sync.Once
+
+//VerifyDummyHash verifies the password against a hash made by the
+//configured Hasher and ignores the result. Call it when a user isn't
+//found, so the request takes as long as a real password check.
+func VerifyDummyHash(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = passwordHasher.Hash("dummy password")
+	})
+	if hasher, err := hasherFor(dummyHash); err == nil {
+		verify(hasher, dummyHash, password)
+	}
+}
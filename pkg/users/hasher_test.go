@@ -0,0 +1,75 @@
+package users
+
+import (
+	"strings"
+	"testing"
+)
+
+//testArgon2idHasher uses little memory and time so the tests run quickly
+func testArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{Time: 1, Memory: 64, Threads: 1, SaltLength: 16, KeyLength: 32}
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	hasher := testArgon2idHasher()
+	hash, err := hasher.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash: unexpected error %v", err)
+	}
+	if !strings.HasPrefix(string(hash), "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("Hash: got %s, want a PHC string with the hasher's parameters", hash)
+	}
+	if !hasher.Identifies(hash) {
+		t.Error("Identifies: got false for an Argon2id hash")
+	}
+	if err := hasher.Verify(hash, "correct horse battery staple"); err != nil {
+		t.Errorf("Verify: unexpected error %v", err)
+	}
+	if err := hasher.Verify(hash, "wrong password"); err != ErrMismatchedPassword {
+		t.Errorf("Verify with the wrong password: got %v, want ErrMismatchedPassword", err)
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Error("NeedsRehash: got true for a hash with the hasher's parameters")
+	}
+	stronger := testArgon2idHasher()
+	stronger.Time = 2
+	if !stronger.NeedsRehash(hash) {
+		t.Error("NeedsRehash: got false for a hash with fewer passes than the hasher's")
+	}
+}
+
+func TestArgon2idMalformedHashes(t *testing.T) {
+	//a 16 byte salt and 32 byte key, base64 encoded
+	salt := "c29tZXNhbHRzb21lc2FsdA"
+	key := "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	cases := map[string]string{
+		"empty":          "",
+		"bcrypt":         "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"too few fields": "$argon2id$v=19$m=64,t=1,p=1$" + salt,
+		"argon2i":        "$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key,
+		"old version":    "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key,
+		"bad parameters": "$argon2id$v=19$m=64;t=1;p=1$" + salt + "$" + key,
+		"no passes":      "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key,
+		"no threads":     "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key,
+		"huge memory":    "$argon2id$v=19$m=4294967295,t=1,p=1$" + salt + "$" + key,
+		"little memory":  "$argon2id$v=19$m=8,t=1,p=4$" + salt + "$" + key,
+		"many passes":    "$argon2id$v=19$m=64,t=1000,p=1$" + salt + "$" + key,
+		"bad salt":       "$argon2id$v=19$m=64,t=1,p=1$not*base64$" + key,
+		"short salt":     "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$" + key,
+		"short key":      "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$a2V5",
+		"long key":       "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + strings.Repeat("a2V5", 30),
+	}
+	hasher := testArgon2idHasher()
+	for name, hash := range cases {
+		if err := hasher.Verify([]byte(hash), "password"); err != ErrInvalidHash {
+			t.Errorf("%s: got %v, want ErrInvalidHash", name, err)
+		}
+		if !hasher.NeedsRehash([]byte(hash)) {
+			t.Errorf("%s: NeedsRehash got false for an invalid hash", name)
+		}
+	}
+	valid := "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key
+	if err := hasher.Verify([]byte(valid), "password"); err != ErrMismatchedPassword {
+		t.Errorf("well-formed hash: got %v, want ErrMismatchedPassword", err)
+	}
+}
@@ -0,0 +1,35 @@
+package users
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var passwordVerifyDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spectrum",
+	Name:      "password_verify_duration_seconds",
+	Help:      "Time taken to verify a password against its hash, by algorithm.",
+	Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"algorithm"})
+
+//hasherName returns the name of the hasher's algorithm
+func hasherName(hasher Hasher) string {
+	switch hasher.(type) {
+	case *Argon2idHasher:
+		return "argon2id"
+	case *BcryptHasher:
+		return "bcrypt"
+	default:
+		return "unknown"
+	}
+}
+
+//verify verifies the password against the hash, recording how long it took
+func verify(hasher Hasher, hash []byte, password string) error {
+	start := time.Now()
+	err := hasher.Verify(hash, password)
+	passwordVerifyDuration.WithLabelValues(hasherName(hasher)).Observe(time.Since(start).Seconds())
+	return err
+}
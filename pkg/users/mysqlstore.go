@@ -1,16 +1,20 @@
 package users
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
+	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql" //mysql driver
+	"github.com/2charm/spectrum-api/pkg/tracing"
+	"github.com/go-sql-driver/mysql"
 	// "github.com/info441/assignments-andrewhwang10/servers/gateway/indexes"
 )
 
 //MySQLStore represents a users.Store backed by MySQL.
 type MySQLStore struct {
-	Client *sql.DB
+	Client *tracing.DB
 }
 
 //NewMySQLStore constructs a new MySQLStore
@@ -18,45 +22,94 @@ func NewMySQLStore(db *sql.DB) *MySQLStore {
 	//initialize and return a new MySQLStore struct
 	if db != nil {
 		return &MySQLStore{
-			Client: db,
+			Client: tracing.NewDB(db),
 		}
 	}
 	return nil
 }
 
+//WithContext returns a copy of the store whose queries are traced as part of `ctx`
+func (mss *MySQLStore) WithContext(ctx context.Context) Store {
+	return &MySQLStore{Client: mss.Client.WithContext(ctx)}
+}
+
+//WithContext returns a view of `store` whose queries are traced as
+//part of `ctx`, or `store` itself if it doesn't support tracing
+func WithContext(store Store, ctx context.Context) Store {
+	if mss, ok := store.(*MySQLStore); ok && mss != nil {
+		return mss.WithContext(ctx)
+	}
+	return store
+}
+
 //Store implementation
 
-//GetByID returns the User with the given ID
-func (mss *MySQLStore) GetByID(id int64) (*User, error) {
+//userColumns lists the columns scanned by scanUser, in order
+const userColumns = "user_id, email, pass_hash, user_name, first_name, last_name, totp_secret, totp_enabled, " +
+	"suspended, password_reset_required, share_activity, share_activity_since"
+
+//rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+//scanUser scans a row selected with userColumns into a new User
+func scanUser(row rowScanner) (*User, error) {
 	user := &User{}
-	row := mss.Client.QueryRow("select * from users where user_id=?", id)
+	sharingSince := sql.NullTime{}
 	if err := row.Scan(&user.ID, &user.Email, &user.PassHash, &user.UserName,
-		&user.FirstName, &user.LastName); err != nil {
+		&user.FirstName, &user.LastName, &user.TOTPSecret, &user.TOTPEnabled,
+		&user.Suspended, &user.PasswordResetRequired, &user.ShareActivity, &sharingSince); err != nil {
 		return nil, err
 	}
+	user.SharingSince = sharingSince.Time
 	return user, nil
 }
 
-//GetByEmail returns the User with the given email
-func (mss *MySQLStore) GetByEmail(email string) (*User, error) {
-	user := &User{}
-	row := mss.Client.QueryRow("select * from users where email=?", email)
-	if err := row.Scan(&user.ID, &user.Email, &user.PassHash, &user.UserName,
-		&user.FirstName, &user.LastName); err != nil {
+//loadRoles populates the user's Roles from the user_roles table
+func (mss *MySQLStore) loadRoles(user *User) (*User, error) {
+	rows, err := mss.Client.Query("select role_name from user_roles where user_id=? order by role_name", user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	user.Roles = []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		user.Roles = append(user.Roles, role)
+	}
+	return user, rows.Err()
+}
+
+//getUser returns the User selected by the `where` clause, or
+//ErrUserNotFound if there's none. Other errors are returned as they are.
+func (mss *MySQLStore) getUser(where string, arg interface{}) (*User, error) {
+	user, err := scanUser(mss.Client.QueryRow("select "+userColumns+" from users where "+where, arg))
+	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
 	}
-	return user, nil
+	if err != nil {
+		return nil, err
+	}
+	return mss.loadRoles(user)
+}
+
+//GetByID returns the User with the given ID
+func (mss *MySQLStore) GetByID(id int64) (*User, error) {
+	return mss.getUser("user_id=?", id)
+}
+
+//GetByEmail returns the User with the given email
+func (mss *MySQLStore) GetByEmail(email string) (*User, error) {
+	return mss.getUser("email=?", email)
 }
 
 //GetByUserName returns the User with the given Username
 func (mss *MySQLStore) GetByUserName(username string) (*User, error) {
-	user := &User{}
-	row := mss.Client.QueryRow("select * from users where user_name=?", username)
-	if err := row.Scan(&user.ID, &user.Email, &user.PassHash, &user.UserName,
-		&user.FirstName, &user.LastName); err != nil {
-		return nil, ErrUserNotFound
-	}
-	return user, nil
+	return mss.getUser("user_name=?", username)
 }
 
 //Insert inserts the user into the database, and returns
@@ -65,18 +118,50 @@ func (mss *MySQLStore) Insert(user *User) (*User, error) {
 	insq := "insert into users(email, pass_hash, user_name, first_name, last_name) values (?, ?, ?, ?, ?)"
 	res, err := mss.Client.Exec(insq, user.Email, user.PassHash, user.UserName, user.FirstName, user.LastName)
 	if err != nil {
-		log.Printf("Issue executing sql statement: %v", err)
+		if dupErr := duplicateError(err); dupErr != nil {
+			return nil, dupErr
+		}
 		return nil, err
 	}
 	id, err := res.LastInsertId()
 	if err != nil {
-		log.Print("Error retrieving last insert id")
-		return nil, err
+		return nil, fmt.Errorf("error retrieving last insert id: %w", err)
 	}
 	user.ID = id
 	return user, nil
 }
 
+//errDuplicateEntry is the MySQL error number for a duplicate unique key
+const errDuplicateEntry = 1062
+
+//uniqueFields maps the unique keys of the users table to JSON field names
+var uniqueFields = map[string]string{
+	"email":     "email",
+	"user_name": "userName",
+}
+
+//duplicateError returns a *DuplicateError if the error is a MySQL
+//duplicate key error on a unique field of the users table, or nil.
+//MySQL reports the key as "Duplicate entry '...' for key 'users.email'",
+//without the table name before MySQL 8.0.19.
+func duplicateError(err error) error {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	if !ok || mysqlErr.Number != errDuplicateEntry {
+		return nil
+	}
+	i := strings.LastIndex(mysqlErr.Message, "for key '")
+	if i < 0 {
+		return nil
+	}
+	key := strings.TrimSuffix(mysqlErr.Message[i+len("for key '"):], "'")
+	key = key[strings.LastIndex(key, ".")+1:]
+	field, found := uniqueFields[key]
+	if !found {
+		return nil
+	}
+	return &DuplicateError{Field: field}
+}
+
 //Update applies UserUpdates to the given user ID
 //and returns the newly-updated user
 func (mss *MySQLStore) Update(id int64, updates *Updates) (*User, error) {
@@ -97,3 +182,251 @@ func (mss *MySQLStore) Delete(id int64) error {
 	}
 	return nil
 }
+
+//SetTOTP saves the TOTP secret for the given user ID and
+//whether two-factor authentication is enabled
+func (mss *MySQLStore) SetTOTP(id int64, secret string, enabled bool) error {
+	insq := "update users set totp_secret=?, totp_enabled=? where user_id=?"
+	_, err := mss.Client.Exec(insq, secret, enabled, id)
+	return err
+}
+
+//UseTOTPStep records the time step of a TOTP code accepted for the
+//given user ID, returning ErrInvalidTOTPCode if a code for the same
+//or a later step was already accepted
+func (mss *MySQLStore) UseTOTPStep(id int64, step int64) error {
+	res, err := mss.Client.Exec("update users set totp_last_step=? where user_id=? and totp_last_step<?", step, id, step)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+//SetRecoveryCodes replaces the recovery code hashes for the given user ID
+func (mss *MySQLStore) SetRecoveryCodes(id int64, codeHashes []string) error {
+	tx, err := mss.Client.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("delete from recovery_codes where user_id=?", id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec("insert into recovery_codes(user_id, code_hash) values (?, ?)", id, hash); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+//UseRecoveryCode consumes the recovery code with the given hash,
+//returning ErrInvalidTOTPCode if it doesn't exist or was already used
+func (mss *MySQLStore) UseRecoveryCode(id int64, codeHash string) error {
+	res, err := mss.Client.Exec("delete from recovery_codes where user_id=? and code_hash=?", id, codeHash)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+//AddRole grants the role to the given user ID
+func (mss *MySQLStore) AddRole(id int64, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	_, err := mss.Client.Exec("insert ignore into user_roles(user_id, role_name) values (?, ?)", id, role)
+	return err
+}
+
+//RemoveRole revokes the role from the given user ID
+func (mss *MySQLStore) RemoveRole(id int64, role string) error {
+	_, err := mss.Client.Exec("delete from user_roles where user_id=? and role_name=?", id, role)
+	return err
+}
+
+//Search returns one page of users whose email, user name, first name or
+//last name contains `query`, ordered by ID, along with the total number
+//of matching users. An empty query matches every user.
+func (mss *MySQLStore) Search(query string, offset int, limit int) ([]*User, int, error) {
+	pattern := "%" + escapeLike(query) + "%"
+	where := " from users where email like ? or user_name like ? or first_name like ? or last_name like ?"
+	var total int
+	row := mss.Client.QueryRow("select count(*)"+where, pattern, pattern, pattern, pattern)
+	if err := row.Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := mss.Client.Query("select "+userColumns+where+" order by user_id limit ? offset ?",
+		pattern, pattern, pattern, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	found := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		found = append(found, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	for _, user := range found {
+		if _, err := mss.loadRoles(user); err != nil {
+			return nil, 0, err
+		}
+	}
+	return found, total, nil
+}
+
+//SearchByPrefix returns up to `limit` users who aren't suspended and
+//whose user name, first name, last name or full name starts with
+//`prefix`, ordered by user name
+func (mss *MySQLStore) SearchByPrefix(prefix string, limit int) ([]*User, error) {
+	pattern := escapeLike(prefix) + "%"
+	return mss.queryUsers("select "+userColumns+" from users where not suspended and "+
+		"(user_name like ? or first_name like ? or last_name like ? or concat(first_name, ' ', last_name) like ?) "+
+		"order by user_name limit ?", pattern, pattern, pattern, pattern, limit)
+}
+
+//SetSuspended suspends or unsuspends the given user ID
+func (mss *MySQLStore) SetSuspended(id int64, suspended bool) error {
+	_, err := mss.Client.Exec("update users set suspended=? where user_id=?", suspended, id)
+	return err
+}
+
+//SetPasswordResetRequired sets whether the given user ID must
+//choose a new password at their next sign-in
+func (mss *MySQLStore) SetPasswordResetRequired(id int64, required bool) error {
+	_, err := mss.Client.Exec("update users set password_reset_required=? where user_id=?", required, id)
+	return err
+}
+
+//UpdatePassword replaces the password hash of the given user ID
+func (mss *MySQLStore) UpdatePassword(id int64, passHash []byte) error {
+	_, err := mss.Client.Exec("update users set pass_hash=? where user_id=?", passHash, id)
+	return err
+}
+
+//InsertSignIn records a sign-in attempt to the given user ID
+func (mss *MySQLStore) InsertSignIn(id int64, clientIP string, succeeded bool) error {
+	insq := "insert into sign_in(user_id, attempt_time, client_ip, succeeded) values (?, ?, ?, ?)"
+	_, err := mss.Client.Exec(insq, id, time.Now(), clientIP, succeeded)
+	return err
+}
+
+//GetSignIns returns the sign-in attempts to the given user ID,
+//most recent first
+func (mss *MySQLStore) GetSignIns(id int64) ([]*SignIn, error) {
+	rows, err := mss.Client.Query("select attempt_time, client_ip, succeeded from sign_in "+
+		"where user_id=? order by attempt_time desc", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	signIns := []*SignIn{}
+	for rows.Next() {
+		signIn := &SignIn{}
+		if err := rows.Scan(&signIn.AttemptTime, &signIn.ClientIP, &signIn.Succeeded); err != nil {
+			return nil, err
+		}
+		signIns = append(signIns, signIn)
+	}
+	return signIns, rows.Err()
+}
+
+//escapeLike escapes the wildcard characters of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
+//SetShareActivity sets whether the given user ID shares their
+//reading activity with their followers. Turning sharing on records
+//when, unless it was already on.
+func (mss *MySQLStore) SetShareActivity(id int64, share bool) error {
+	_, err := mss.Client.Exec("update users set share_activity=?, "+
+		"share_activity_since=case when ? then coalesce(share_activity_since, ?) else null end where user_id=?",
+		share, share, time.Now(), id)
+	return err
+}
+
+//Follow makes the follower follow the followee. Following
+//a user that is already followed does nothing.
+func (mss *MySQLStore) Follow(followerID int64, followeeID int64) error {
+	_, err := mss.Client.Exec("insert ignore into follows(follower_id, followee_id, created_at) values (?, ?, ?)",
+		followerID, followeeID, time.Now())
+	return err
+}
+
+//Unfollow makes the follower stop following the followee
+func (mss *MySQLStore) Unfollow(followerID int64, followeeID int64) error {
+	_, err := mss.Client.Exec("delete from follows where follower_id=? and followee_id=?", followerID, followeeID)
+	return err
+}
+
+//GetFollowing returns the users the given user ID follows, ordered by user name
+func (mss *MySQLStore) GetFollowing(id int64) ([]*User, error) {
+	return mss.queryUsers("select "+prefixColumns("users.", userColumns)+" from users "+
+		"inner join follows on follows.followee_id=users.user_id "+
+		"where follows.follower_id=? and not users.suspended order by users.user_name", id)
+}
+
+//GetFollowers returns the users following the given user ID, ordered by user name
+func (mss *MySQLStore) GetFollowers(id int64) ([]*User, error) {
+	return mss.queryUsers("select "+prefixColumns("users.", userColumns)+" from users "+
+		"inner join follows on follows.follower_id=users.user_id "+
+		"where follows.followee_id=? and not users.suspended order by users.user_name", id)
+}
+
+//GetSharingFollowees returns the users the given user ID follows
+//who share their reading activity, ordered by user name
+func (mss *MySQLStore) GetSharingFollowees(id int64) ([]*User, error) {
+	return mss.queryUsers("select "+prefixColumns("users.", userColumns)+" from users "+
+		"inner join follows on follows.followee_id=users.user_id "+
+		"where follows.follower_id=? and users.share_activity and not users.suspended "+
+		"order by users.user_name", id)
+}
+
+//queryUsers runs a query selecting userColumns and scans every resulting user
+func (mss *MySQLStore) queryUsers(query string, args ...interface{}) ([]*User, error) {
+	rows, err := mss.Client.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, user)
+	}
+	return found, rows.Err()
+}
+
+//prefixColumns qualifies each column in a comma separated list with a table prefix
+func prefixColumns(prefix string, columns string) string {
+	fields := strings.Split(columns, ",")
+	for i, field := range fields {
+		fields[i] = prefix + strings.TrimSpace(field)
+	}
+	return strings.Join(fields, ", ")
+}
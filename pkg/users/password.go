@@ -0,0 +1,326 @@
+package users
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//PasswordReason codes returned in a PasswordError
+const (
+	ReasonTooShort     = "too_short"
+	ReasonTooWeak      = "too_weak"
+	ReasonBreached     = "breached"
+	ReasonUserInfo     = "contains_user_info"
+	ReasonBreachedList = "breached_list_unavailable"
+)
+
+//PasswordReason explains one rule a password failed
+type PasswordReason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+//PasswordError is returned when a password fails the password policy
+type PasswordError struct {
+	Reasons []PasswordReason `json:"reasons"`
+}
+
+//Error returns the messages of every reason the password was rejected
+func (pe *PasswordError) Error() string {
+	messages := make([]string, len(pe.Reasons))
+	for i, reason := range pe.Reasons {
+		messages[i] = reason.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+//PasswordPolicy holds the rules new passwords must follow
+type PasswordPolicy struct {
+	//MinLength is the minimum number of characters
+	MinLength int
+	//MinScore is the minimum strength from EstimateStrength, 0 to 4
+	MinScore int
+	//Breached, if not nil, rejects passwords found in a breach
+	Breached *BreachedList
+}
+
+//passwordPolicy is the policy applied to new passwords
+var passwordPolicy = &PasswordPolicy{
+	MinLength: 8,
+	MinScore:  2,
+}
+
+//SetPasswordPolicy replaces the policy applied to new passwords
+func SetPasswordPolicy(policy *PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+//Check returns a *PasswordError listing every rule the password breaks,
+//or nil if it follows the policy. `userInputs` are values such as the
+//user name and email that the password shouldn't be built from.
+func (pp *PasswordPolicy) Check(password string, userInputs ...string) error {
+	reasons := []PasswordReason{}
+	if len([]rune(password)) < pp.MinLength {
+		reasons = append(reasons, PasswordReason{ReasonTooShort,
+			fmt.Sprintf("Password must be at least %d characters", pp.MinLength)})
+	}
+	if containsUserInput(password, userInputs) {
+		reasons = append(reasons, PasswordReason{ReasonUserInfo,
+			"Password must not contain your user name, email or name"})
+	}
+	if EstimateStrength(password, userInputs...) < pp.MinScore {
+		reasons = append(reasons, PasswordReason{ReasonTooWeak,
+			"Password is too easy to guess, try a longer phrase or fewer patterns"})
+	}
+	if pp.Breached != nil {
+		breached, err := pp.Breached.Contains(password)
+		if err != nil {
+			reasons = append(reasons, PasswordReason{ReasonBreachedList,
+				"Password could not be checked against known breaches"})
+		} else if breached {
+			reasons = append(reasons, PasswordReason{ReasonBreached,
+				"Password has appeared in a data breach and must not be used"})
+		}
+	}
+	if len(reasons) > 0 {
+		return &PasswordError{Reasons: reasons}
+	}
+	return nil
+}
+
+//commonPasswords are rejected outright by EstimateStrength
+var commonPasswords = map[string]bool{
+	"password": true, "123456": true, "12345678": true, "123456789": true, "1234567890": true,
+	"qwerty": true, "qwertyuiop": true, "abc123": true, "111111": true, "123123": true,
+	"letmein": true, "welcome": true, "monkey": true, "dragon": true, "football": true,
+	"baseball": true, "iloveyou": true, "admin": true, "login": true, "master": true,
+	"sunshine": true, "princess": true, "passw0rd": true, "password1": true, "trustno1": true,
+	"shadow": true, "superman": true, "michael": true, "starwars": true, "whatever": true,
+	"654321": true, "000000": true, "1q2w3e4r": true, "qwerty123": true, "zaq12wsx": true,
+	"spectrum": true, "spectrumnews": true, "news": true,
+}
+
+//keyboardRows are treated as sequences by EstimateStrength
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890"}
+
+//EstimateStrength returns a zxcvbn-style score from 0 (trivially guessable)
+//to 4 (very unlikely to be guessed). It estimates the bits of entropy in the
+//password, counting repeated characters, sequences, keyboard runs and parts
+//copied from `userInputs` as nearly free for an attacker to guess.
+func EstimateStrength(password string, userInputs ...string) int {
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] || commonPasswords[strings.TrimRight(lower, "0123456789!")] {
+		return 0
+	}
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	cardinality := 0
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range runes {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	if hasLower {
+		cardinality += 26
+	}
+	if hasUpper {
+		cardinality += 26
+	}
+	if hasDigit {
+		cardinality += 10
+	}
+	if hasSymbol {
+		cardinality += 33
+	}
+	charBits := math.Log2(float64(cardinality))
+
+	//mark characters an attacker gets nearly for free
+	free := make([]bool, len(runes))
+	lowerRunes := []rune(lower)
+	for i := 1; i < len(lowerRunes); i++ {
+		prev, cur := lowerRunes[i-1], lowerRunes[i]
+		if cur == prev || cur == prev+1 || cur == prev-1 || keyboardAdjacent(prev, cur) {
+			free[i] = true
+		}
+	}
+	for _, input := range userInputs {
+		for _, part := range userInputParts(input) {
+			for start := strings.Index(lower, part); start >= 0; {
+				for i := len([]rune(lower[:start])); i < len([]rune(lower[:start+len(part)])); i++ {
+					free[i] = true
+				}
+				next := strings.Index(lower[start+len(part):], part)
+				if next < 0 {
+					break
+				}
+				start += len(part) + next
+			}
+		}
+	}
+
+	bits := 0.0
+	for i := range runes {
+		if free[i] {
+			bits++
+		} else {
+			bits += charBits
+		}
+	}
+
+	switch {
+	case bits < 10:
+		return 0
+	case bits < 20:
+		return 1
+	case bits < 27:
+		return 2
+	case bits < 34:
+		return 3
+	}
+	return 4
+}
+
+//keyboardAdjacent returns true if `b` follows `a` on a keyboard row
+func keyboardAdjacent(a rune, b rune) bool {
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, a)
+		if i >= 0 && i+1 < len(row) && rune(row[i+1]) == b {
+			return true
+		}
+	}
+	return false
+}
+
+//userInputParts splits a user input, such as an email, into the lowercase
+//parts of at least three characters a password might reuse
+func userInputParts(input string) []string {
+	parts := []string{}
+	for _, part := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return r == '@' || r == '.' || r == ' ' || r == '_' || r == '-'
+	}) {
+		if len(part) >= 3 {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+//containsUserInput returns true if the password contains any part of the user inputs
+func containsUserInput(password string, userInputs []string) bool {
+	lower := strings.ToLower(password)
+	for _, input := range userInputs {
+		for _, part := range userInputParts(input) {
+			if len(part) >= 4 && strings.Contains(lower, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//hashPrefixLength is the length of the SHA-1 hash prefix that
+//names a range file in a k-anonymity breached-password corpus
+const hashPrefixLength = 5
+
+//BreachedList checks passwords against a local breached-password corpus,
+//so the check works offline. The corpus is either a directory of range files
+//named by the first five hex characters of the SHA-1 hash, each holding lines
+//of "SUFFIX:COUNT" as served by the Pwned Passwords range API, or a single
+//file holding lines of "HASH" or "HASH:COUNT".
+type BreachedList struct {
+	dir    string
+	hashes map[string]map[string]bool
+	mx     sync.RWMutex
+}
+
+//LoadBreachedList loads the breached-password corpus at `path`.
+//Range files in a directory are read when a password with their prefix is checked.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	bl := &BreachedList{hashes: map[string]map[string]bool{}}
+	if info.IsDir() {
+		bl.dir = path
+		return bl, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash := strings.ToUpper(strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)[0])
+		if len(hash) != sha1.Size*2 {
+			continue
+		}
+		prefix := hash[:hashPrefixLength]
+		if bl.hashes[prefix] == nil {
+			bl.hashes[prefix] = map[string]bool{}
+		}
+		bl.hashes[prefix][hash[hashPrefixLength:]] = true
+	}
+	return bl, scanner.Err()
+}
+
+//Contains returns true if the password appears in the breached-password corpus
+func (bl *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+	if bl.dir == "" {
+		return bl.hashes[prefix][suffix], nil
+	}
+
+	bl.mx.RLock()
+	suffixes, loaded := bl.hashes[prefix]
+	bl.mx.RUnlock()
+	if !loaded {
+		suffixes = map[string]bool{}
+		f, err := os.Open(filepath.Join(bl.dir, prefix))
+		if os.IsNotExist(err) {
+			f, err = os.Open(filepath.Join(bl.dir, prefix+".txt"))
+		}
+		if os.IsNotExist(err) {
+			//no breached hashes share this prefix
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.ToUpper(strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)[0])
+			suffixes[line] = true
+		}
+		if err := scanner.Err(); err != nil {
+			return false, err
+		}
+		bl.mx.Lock()
+		bl.hashes[prefix] = suffixes
+		bl.mx.Unlock()
+	}
+	return suffixes[suffix], nil
+}
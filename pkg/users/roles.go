@@ -0,0 +1,64 @@
+package users
+
+import (
+	"errors"
+)
+
+//RoleAdmin can use every admin API
+const RoleAdmin = "admin"
+
+//RoleModerator can moderate user accounts
+const RoleModerator = "moderator"
+
+//RoleEditor can curate public collections of articles
+const RoleEditor = "editor"
+
+//PermManageUsers allows searching, suspending and resetting user accounts
+const PermManageUsers = "users:manage"
+
+//PermManageCategories allows editing the news categories
+const PermManageCategories = "categories:manage"
+
+//PermRateSources allows editing news source ratings
+const PermRateSources = "sources:rate"
+
+//PermCurateCollections allows creating collections of articles
+const PermCurateCollections = "collections:curate"
+
+//ErrInvalidRole is returned when a role name isn't one of the known roles
+var ErrInvalidRole = errors.New("invalid role")
+
+//rolePermissions maps each role to the permissions it grants
+var rolePermissions = map[string][]string{
+	RoleAdmin:     {PermManageUsers, PermManageCategories, PermRateSources, PermCurateCollections},
+	RoleModerator: {PermManageUsers},
+	RoleEditor:    {PermCurateCollections},
+}
+
+//ValidRole returns true if `role` is one of the known roles
+func ValidRole(role string) bool {
+	_, found := rolePermissions[role]
+	return found
+}
+
+//HasRole returns true if the user has been granted the role
+func (u *User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+//Can returns true if any of the user's roles grants the permission
+func (u *User) Can(permission string) bool {
+	for _, role := range u.Roles {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -2,24 +2,41 @@ package users
 
 import (
 	"errors"
+	"fmt"
 )
 
 //ErrUserNotFound is returned when the user can't be found
 var ErrUserNotFound = errors.New("user not found")
 
+//DuplicateError is returned when an insert would give a user the
+//same value as another user in a unique field
+type DuplicateError struct {
+	//Field is the JSON name of the duplicated field, such as "email"
+	Field string
+}
+
+//Error names the duplicated field
+func (de *DuplicateError) Error() string {
+	return fmt.Sprintf("a user with that %s already exists", de.Field)
+}
+
 //Store represents a store for Users
 type Store interface {
-	//GetByID returns the User with the given ID
+	//GetByID returns the User with the given ID,
+	//or ErrUserNotFound if there's none
 	GetByID(id int64) (*User, error)
 
-	//GetByEmail returns the User with the given email
+	//GetByEmail returns the User with the given email,
+	//or ErrUserNotFound if there's none
 	GetByEmail(email string) (*User, error)
 
-	//GetByUserName returns the User with the given Username
+	//GetByUserName returns the User with the given Username,
+	//or ErrUserNotFound if there's none
 	GetByUserName(username string) (*User, error)
 
 	//Insert inserts the user into the database, and returns
-	//the newly-inserted User, complete with the DBMS-assigned ID
+	//the newly-inserted User, complete with the DBMS-assigned ID.
+	//A *DuplicateError is returned if the email or user name is taken.
 	Insert(user *User) (*User, error)
 
 	//Update applies UserUpdates to the given user ID
@@ -28,4 +45,75 @@ type Store interface {
 
 	//Delete deletes the user with the given ID
 	Delete(id int64) error
+
+	//SetTOTP saves the TOTP secret for the given user ID and
+	//whether two-factor authentication is enabled
+	SetTOTP(id int64, secret string, enabled bool) error
+
+	//UseTOTPStep records the time step of a TOTP code accepted for the
+	//given user ID, returning ErrInvalidTOTPCode if a code for the same
+	//or a later step was already accepted
+	UseTOTPStep(id int64, step int64) error
+
+	//SetRecoveryCodes replaces the recovery code hashes for the given user ID
+	SetRecoveryCodes(id int64, codeHashes []string) error
+
+	//UseRecoveryCode consumes the recovery code with the given hash,
+	//returning ErrInvalidTOTPCode if it doesn't exist or was already used
+	UseRecoveryCode(id int64, codeHash string) error
+
+	//AddRole grants the role to the given user ID
+	AddRole(id int64, role string) error
+
+	//RemoveRole revokes the role from the given user ID
+	RemoveRole(id int64, role string) error
+
+	//Search returns one page of users whose email, user name, first name or
+	//last name contains `query`, ordered by ID, along with the total number
+	//of matching users. An empty query matches every user.
+	Search(query string, offset int, limit int) ([]*User, int, error)
+
+	//SearchByPrefix returns up to `limit` users who aren't suspended and
+	//whose user name, first name, last name or full name starts with
+	//`prefix`, ordered by user name
+	SearchByPrefix(prefix string, limit int) ([]*User, error)
+
+	//SetSuspended suspends or unsuspends the given user ID
+	SetSuspended(id int64, suspended bool) error
+
+	//SetPasswordResetRequired sets whether the given user ID must
+	//choose a new password at their next sign-in
+	SetPasswordResetRequired(id int64, required bool) error
+
+	//UpdatePassword replaces the password hash of the given user ID
+	UpdatePassword(id int64, passHash []byte) error
+
+	//InsertSignIn records a sign-in attempt to the given user ID
+	InsertSignIn(id int64, clientIP string, succeeded bool) error
+
+	//GetSignIns returns the sign-in attempts to the given user ID,
+	//most recent first
+	GetSignIns(id int64) ([]*SignIn, error)
+
+	//SetShareActivity sets whether the given user ID shares their
+	//reading activity with their followers. Turning sharing on records
+	//when, unless it was already on.
+	SetShareActivity(id int64, share bool) error
+
+	//Follow makes the follower follow the followee. Following
+	//a user that is already followed does nothing.
+	Follow(followerID int64, followeeID int64) error
+
+	//Unfollow makes the follower stop following the followee
+	Unfollow(followerID int64, followeeID int64) error
+
+	//GetFollowing returns the users the given user ID follows, ordered by user name
+	GetFollowing(id int64) ([]*User, error)
+
+	//GetFollowers returns the users following the given user ID, ordered by user name
+	GetFollowers(id int64) ([]*User, error)
+
+	//GetSharingFollowees returns the users the given user ID follows
+	//who share their reading activity, ordered by user name
+	GetSharingFollowees(id int64) ([]*User, error)
 }
@@ -0,0 +1,132 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//totpPeriod is the RFC 6238 time step
+const totpPeriod = 30
+
+//totpDigits is the number of digits in a generated code
+const totpDigits = 6
+
+//totpModulo truncates a HOTP value to `totpDigits` digits
+const totpModulo = 1000000
+
+//totpSkew is the number of time steps on either side of the
+//current one that are still accepted, to allow for clock drift
+const totpSkew = 1
+
+//totpSecretLength is the number of random bytes in a TOTP secret
+const totpSecretLength = 20
+
+//recoveryCodeCount is the number of recovery codes issued at enrollment
+const recoveryCodeCount = 10
+
+//recoveryCodeLength is the number of random bytes in a recovery code
+const recoveryCodeLength = 5
+
+//ErrInvalidTOTPCode is returned when a TOTP or recovery code does not match
+var ErrInvalidTOTPCode = errors.New("invalid two-factor code")
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+//NewTOTPSecret generates a new random base32 encoded TOTP secret
+func NewTOTPSecret() (string, error) {
+	secret := make([]byte, totpSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+//TOTPProvisioningURI returns the otpauth:// URI an authenticator app
+//uses to enroll the given secret for the account
+func TOTPProvisioningURI(issuer string, account string, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+//TOTPCode returns the code for the secret at the given time
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(t.Unix()/totpPeriod)), nil
+}
+
+//ValidateTOTP returns nil if the code is valid for the secret at the given
+//time, allowing for `totpSkew` steps of clock drift, or ErrInvalidTOTPCode
+func ValidateTOTP(secret string, code string, t time.Time) error {
+	_, err := MatchTOTP(secret, code, t)
+	return err
+}
+
+//MatchTOTP returns the time step the code is valid for at the given time,
+//allowing for `totpSkew` steps of clock drift, or ErrInvalidTOTPCode.
+//Recording the step with Store.UseTOTPStep stops the code being replayed.
+func MatchTOTP(secret string, code string, t time.Time) (int64, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, ErrInvalidTOTPCode
+	}
+	step := t.Unix() / totpPeriod
+	for i := int64(-totpSkew); i <= totpSkew; i++ {
+		if hmac.Equal([]byte(hotp(key, uint64(step+i))), []byte(code)) {
+			return step + i, nil
+		}
+	}
+	return 0, ErrInvalidTOTPCode
+}
+
+//hotp computes the RFC 4226 HOTP value for the key and counter
+func hotp(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	h := hmac.New(sha1.New, key)
+	h.Write(msg)
+	sum := h.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulo)
+}
+
+//NewRecoveryCodes generates a fresh set of single-use recovery codes
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(buf))
+		codes[i] = code[:4] + "-" + code[4:]
+	}
+	return codes, nil
+}
+
+//HashRecoveryCode returns the hash of a recovery code as it is kept in
+//the store. Recovery codes are high entropy, so a fast hash is enough.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package users
+
+import (
+	"testing"
+	"time"
+)
+
+//rfc6238Secret is the SHA1 seed of the RFC 6238 test vectors, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	//the RFC 6238 SHA1 vectors, truncated to the last six of their eight digits
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, c := range cases {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(c.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode at %d: unexpected error %v", c.unix, err)
+		}
+		if code != c.code {
+			t.Errorf("TOTPCode at %d: got %s, want %s", c.unix, code, c.code)
+		}
+	}
+}
+
+func TestTOTPCodeLowercaseSecret(t *testing.T) {
+	code, err := TOTPCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", time.Unix(59, 0))
+	if err != nil || code != "287082" {
+		t.Errorf("got %q, %v, want 287082", code, err)
+	}
+}
+
+func TestMatchTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+	cases := []struct {
+		offset int64
+		valid  bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+	for _, c := range cases {
+		code, err := TOTPCode(rfc6238Secret, time.Unix((step+c.offset)*totpPeriod, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode: unexpected error %v", err)
+		}
+		matched, err := MatchTOTP(rfc6238Secret, code, now)
+		if !c.valid {
+			if err != ErrInvalidTOTPCode {
+				t.Errorf("offset %d: got %v, want ErrInvalidTOTPCode", c.offset, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("offset %d: unexpected error %v", c.offset, err)
+		} else if matched != step+c.offset {
+			t.Errorf("offset %d: matched step %d, want %d", c.offset, matched, step+c.offset)
+		}
+	}
+}
+
+func TestMatchTOTPInvalid(t *testing.T) {
+	now := time.Unix(59, 0)
+	cases := map[string]struct {
+		secret string
+		code   string
+	}{
+		"wrong code":     {rfc6238Secret, "123456"},
+		"short code":     {rfc6238Secret, "28708"},
+		"long code":      {rfc6238Secret, "94287082"},
+		"empty code":     {rfc6238Secret, ""},
+		"invalid secret": {"not base32!", "287082"},
+	}
+	for name, c := range cases {
+		if _, err := MatchTOTP(c.secret, c.code, now); err != ErrInvalidTOTPCode {
+			t.Errorf("%s: got %v, want ErrInvalidTOTPCode", name, err)
+		}
+	}
+	if err := ValidateTOTP(rfc6238Secret, "287082", now); err != nil {
+		t.Errorf("ValidateTOTP: unexpected error %v", err)
+	}
+}
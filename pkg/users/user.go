@@ -4,13 +4,9 @@ import (
 	"fmt"
 	"net/mail"
 	"strings"
-
-	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
-//bcryptCost is the default bcrypt cost to use when hashing passwords
-var bcryptCost = 13
-
 //User represents a user account in the database
 type User struct {
 	ID        int64  `json:"id"`
@@ -19,6 +15,35 @@ type User struct {
 	UserName  string `json:"userName"`
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
+	//TOTPSecret is the base32 encoded secret for two-factor authentication
+	TOTPSecret  string `json:"-"` //never JSON encoded/decoded
+	TOTPEnabled bool   `json:"totpEnabled"`
+	//Roles are the names of the roles granted to the user
+	Roles []string `json:"roles,omitempty"`
+	//Suspended users may not sign in
+	Suspended bool `json:"suspended,omitempty"`
+	//PasswordResetRequired users must choose a new password before
+	//their session is usable
+	PasswordResetRequired bool `json:"passwordResetRequired,omitempty"`
+	//ShareActivity users let their followers see what they read
+	ShareActivity bool `json:"shareActivity"`
+	//SharingSince is when the user last turned on ShareActivity.
+	//Followers only see what they read after it.
+	SharingSince time.Time `json:"-"`
+}
+
+//PublicProfile is the part of a user's profile other users may see
+type PublicProfile struct {
+	ID       int64  `json:"id"`
+	UserName string `json:"userName"`
+	FullName string `json:"fullName"`
+}
+
+//SignIn represents one sign-in attempt to a user account
+type SignIn struct {
+	AttemptTime time.Time `json:"attemptTime"`
+	ClientIP    string    `json:"clientIP"`
+	Succeeded   bool      `json:"succeeded"`
 }
 
 //Credentials represents user sign-in credentials
@@ -37,29 +62,112 @@ type NewUser struct {
 	LastName     string `json:"lastName"`
 }
 
+//PasswordChange represents a request to replace a user's password
+type PasswordChange struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+	NewPasswordConf string `json:"newPasswordConf"`
+}
+
 //Updates represents allowed updates to a user profile
 type Updates struct {
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
 }
 
-//Validate validates the new user and returns an error if
-//any of the validation rules fail, or nil if its valid
+//FieldError describes why one field failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	//Reasons lists the password policy rules a password field broke
+	Reasons []PasswordReason `json:"reasons,omitempty"`
+}
+
+//ValidationError is returned when one or more fields fail validation
+type ValidationError struct {
+	Fields []*FieldError `json:"fields"`
+}
+
+//Error returns the messages of every field that failed validation
+func (ve *ValidationError) Error() string {
+	messages := make([]string, len(ve.Fields))
+	for i, field := range ve.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+//add records a failing field
+func (ve *ValidationError) add(field string, message string) {
+	ve.Fields = append(ve.Fields, &FieldError{Field: field, Message: message})
+}
+
+//addPassword records a password field that failed the password policy
+func (ve *ValidationError) addPassword(field string, err error) {
+	fe := &FieldError{Field: field, Message: err.Error()}
+	if pwErr, ok := err.(*PasswordError); ok {
+		fe.Message = "Password does not meet the password policy"
+		fe.Reasons = pwErr.Reasons
+	}
+	ve.Fields = append(ve.Fields, fe)
+}
+
+//errOrNil returns the ValidationError if any field failed, or nil
+func (ve *ValidationError) errOrNil() error {
+	if len(ve.Fields) > 0 {
+		return ve
+	}
+	return nil
+}
+
+//Maximum field lengths, matching the users table
+const (
+	maxEmailLength     = 128
+	maxUserNameLength  = 256
+	maxFirstNameLength = 64
+	maxLastNameLength  = 128
+)
+
+//Validate validates the new user and returns a *ValidationError
+//listing every field that fails the validation rules, or nil if its valid
 func (nu *NewUser) Validate() error {
-	_, err := mail.ParseAddress(nu.Email)
-	if err != nil {
-		return fmt.Errorf("Email field must be a valid email address")
+	ve := &ValidationError{}
+	if _, err := mail.ParseAddress(nu.Email); err != nil {
+		ve.add("email", "Email field must be a valid email address")
+	} else if len(nu.Email) > maxEmailLength {
+		ve.add("email", fmt.Sprintf("Email must be at most %d characters", maxEmailLength))
 	}
-	if len(nu.Password) < 6 {
-		return fmt.Errorf("Password must be at least 6 characters")
+	if err := passwordPolicy.Check(nu.Password, nu.Email, nu.UserName, nu.FirstName, nu.LastName); err != nil {
+		ve.addPassword("password", err)
 	}
 	if nu.Password != nu.PasswordConf {
-		return fmt.Errorf("Password and PasswordConf must match")
+		ve.add("passwordConf", "Password and PasswordConf must match")
 	}
 	if len(nu.UserName) == 0 || strings.Contains(nu.UserName, " ") {
-		return fmt.Errorf("UserName must be non-zero length and may not contain spaces")
+		ve.add("userName", "UserName must be non-zero length and may not contain spaces")
+	} else if len(nu.UserName) > maxUserNameLength {
+		ve.add("userName", fmt.Sprintf("UserName must be at most %d characters", maxUserNameLength))
 	}
-	return nil
+	if len(nu.FirstName) > maxFirstNameLength {
+		ve.add("firstName", fmt.Sprintf("FirstName must be at most %d characters", maxFirstNameLength))
+	}
+	if len(nu.LastName) > maxLastNameLength {
+		ve.add("lastName", fmt.Sprintf("LastName must be at most %d characters", maxLastNameLength))
+	}
+	return ve.errOrNil()
+}
+
+//Validate validates the password change for the user and returns a
+//*ValidationError if the new password is invalid, or nil if its valid
+func (pc *PasswordChange) Validate(u *User) error {
+	ve := &ValidationError{}
+	if err := passwordPolicy.Check(pc.NewPassword, u.Email, u.UserName, u.FirstName, u.LastName); err != nil {
+		ve.addPassword("newPassword", err)
+	}
+	if pc.NewPassword != pc.NewPasswordConf {
+		ve.add("newPasswordConf", "NewPassword and NewPasswordConf must match")
+	}
+	return ve.errOrNil()
 }
 
 //ToUser converts the NewUser to a User, setting the
@@ -99,11 +207,19 @@ func (u *User) FullName() string {
 	}
 }
 
-//SetPassword hashes the password and stores it in the PassHash field
+//PublicProfile returns the user's public profile
+func (u *User) PublicProfile() *PublicProfile {
+	return &PublicProfile{
+		ID:       u.ID,
+		UserName: u.UserName,
+		FullName: u.FullName(),
+	}
+}
+
+//SetPassword hashes the password with the configured
+//Hasher and stores it in the PassHash field
 func (u *User) SetPassword(password string) error {
-	//TODO: use the bcrypt package to generate a new hash of the password
-	//https://godoc.org/golang.org/x/crypto/bcrypt
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	hash, err := passwordHasher.Hash(password)
 	if err != nil {
 		return err
 	}
@@ -114,7 +230,18 @@ func (u *User) SetPassword(password string) error {
 //Authenticate compares the plaintext password against the stored hash
 //and returns an error if they don't match, or nil if they do
 func (u *User) Authenticate(password string) error {
-	return bcrypt.CompareHashAndPassword(u.PassHash, []byte(password))
+	hasher, err := hasherFor(u.PassHash)
+	if err != nil {
+		return err
+	}
+	return verify(hasher, u.PassHash, password)
+}
+
+//NeedsRehash returns true if the stored hash wasn't made by the
+//configured Hasher with its current parameters
+func (u *User) NeedsRehash() bool {
+	hasher, err := hasherFor(u.PassHash)
+	return err != nil || hasher != passwordHasher || hasher.NeedsRehash(u.PassHash)
 }
 
 //ApplyUpdates applies the updates to the user. An error
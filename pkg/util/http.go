@@ -0,0 +1,59 @@
+package util
+
+import (
+	"net"
+	"net/http"
+)
+
+//ClientIP returns the IP address of the client that sent the request
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+//StatusRecorder records the status code and number of bytes written to a
+//ResponseWriter. The logging, metrics and tracing middleware each wrap the
+//ResponseWriter in one to learn how the request was answered.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status      int
+	Bytes       int
+	wroteHeader bool
+}
+
+//NewStatusRecorder constructs a StatusRecorder wrapping `w`. The status
+//is 200 until the handler writes a different one.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (sr *StatusRecorder) WriteHeader(status int) {
+	if !sr.wroteHeader {
+		sr.Status = status
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *StatusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	n, err := sr.ResponseWriter.Write(b)
+	sr.Bytes += n
+	return n, err
+}
+
+//Flush flushes the underlying ResponseWriter, if it supports it,
+//so proxied responses can still be streamed
+func (sr *StatusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (sr *StatusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
@@ -1,14 +1,15 @@
 package util
 
 import (
-	"log"
+	"log/slog"
 	"os"
 )
 
 // FailOnError logs a fatal error, with text msg, if err is not nil.
 func FailOnError(err error, msg string) {
 	if err != nil {
-		log.Fatalf("%s: %s", msg, err)
+		slog.Error(msg, "error", err)
+		os.Exit(1)
 	}
 }
 
@@ -17,7 +18,18 @@ func FailOnError(err error, msg string) {
 func GetEnvironmentVariable(key string) string {
 	val, set := os.LookupEnv(key)
 	if !set {
-		log.Fatalf("%s environment variable is not set", key)
+		slog.Error("environment variable is not set", "variable", key)
+		os.Exit(1)
+	}
+	return val
+}
+
+// GetEnvironmentVariableOrDefault retrieves environment variable, key,
+// or returns def if it is not set.
+func GetEnvironmentVariableOrDefault(key string, def string) string {
+	val, set := os.LookupEnv(key)
+	if !set {
+		return def
 	}
 	return val
 }
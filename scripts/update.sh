@@ -9,17 +9,44 @@ docker network rm service_network
 docker network create service_network
 
 #Set env variables
+export READY_TRIES=${READY_TRIES:-60}
 export ADDR=:443
 export REDISADDR="redis_server:6379"
 export NEWSADDR=:80
 export APIKEY="`cat ./news_api.key`"
+export IDENTITYKEY="`cat ./identity.key`"
 export SESSIONKEY="keykey"
 export SQLADDR=3306
 export MYSQL_ROOT_PASSWORD="sqlkey"
-export DSN="root:$MYSQL_ROOT_PASSWORD@tcp(sql_server:$SQLADDR)/mysql"
+export DSN="root:$MYSQL_ROOT_PASSWORD@tcp(sql_server:$SQLADDR)/mysql?parseTime=true"
 export TLSCERT="/etc/letsencrypt/live/api.spectrumnews.me/fullchain.pem"
 export TLSKEY="/etc/letsencrypt/live/api.spectrumnews.me/privkey.pem"
 
+#cleanup removes the containers and network this script started
+cleanup() {
+    docker rm -f gateway news_service sql_server redis_server
+    docker network rm service_network
+}
+
+#wait_ready runs a readiness command in a container once a second until it
+#succeeds. After READY_TRIES failed attempts it prints the container's logs,
+#cleans up and exits.
+wait_ready() {
+    container=$1
+    shift
+    tries=0
+    until docker exec "$container" "$@"; do
+        tries=$((tries+1))
+        if [ $tries -ge $READY_TRIES ]; then
+            echo "$container was not ready after $READY_TRIES attempts" >&2
+            docker logs --tail 100 "$container" >&2
+            cleanup
+            exit 1
+        fi
+        sleep 1
+    done
+}
+
 #Redis Server
 docker run -d --network service_network --name redis_server redis
 #SQL Server
@@ -30,18 +57,20 @@ docker run -d --network service_network --name sql_server \
 2charm/sql
 
 #Ensure server is up and running before api is running
-sleep 20
+#(mysqladmin over TCP fails while the image runs its init scripts)
+wait_ready sql_server mysqladmin ping -h 127.0.0.1 -p$MYSQL_ROOT_PASSWORD --silent
 
 #News Service
 docker pull 2charm/news_service
 docker run -d --network service_network --name news_service \
--p 80:80 \
 -e ADDR=$NEWSADDR \
+-e IDENTITYKEY=$IDENTITYKEY \
 -e DSN=$DSN \
 -e APIKEY=$APIKEY \
 2charm/news_service
 
-sleep 20
+#Wait for the news service to report ready before the gateway proxies to it
+wait_ready news_service wget -q -O /dev/null http://localhost$NEWSADDR/readyz
 
 #Gateway
 docker pull 2charm/gateway
@@ -54,5 +83,9 @@ docker run -d --network service_network --name gateway \
 -e NEWSADDR="news_service$NEWSADDR" \
 -e REDISADDR=$REDISADDR \
 -e SESSIONKEY=$SESSIONKEY \
+-e IDENTITYKEY=$IDENTITYKEY \
 -e DSN=$DSN \
-2charm/gateway
\ No newline at end of file
+2charm/gateway
+
+#Wait for the gateway to report ready
+wait_ready gateway wget -q -O /dev/null --no-check-certificate https://localhost$ADDR/readyz
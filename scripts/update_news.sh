@@ -3,17 +3,46 @@ docker login
 docker rm -f news_service
 
 #Set env variables
+export READY_TRIES=${READY_TRIES:-60}
 export NEWSADDR=:80
 export APIKEY="`cat ./news_api.key`"
+export IDENTITYKEY="`cat ./identity.key`"
 export SQLADDR=3306
 export MYSQL_ROOT_PASSWORD="sqlkey"
-export DSN="root:$MYSQL_ROOT_PASSWORD@tcp(sql_server:$SQLADDR)/mysql"
+export DSN="root:$MYSQL_ROOT_PASSWORD@tcp(sql_server:$SQLADDR)/mysql?parseTime=true"
+
+#cleanup removes the news service container this script started
+cleanup() {
+    docker rm -f news_service
+}
+
+#wait_ready runs a readiness command in a container once a second until it
+#succeeds. After READY_TRIES failed attempts it prints the container's logs,
+#cleans up and exits.
+wait_ready() {
+    container=$1
+    shift
+    tries=0
+    until docker exec "$container" "$@"; do
+        tries=$((tries+1))
+        if [ $tries -ge $READY_TRIES ]; then
+            echo "$container was not ready after $READY_TRIES attempts" >&2
+            docker logs --tail 100 "$container" >&2
+            cleanup
+            exit 1
+        fi
+        sleep 1
+    done
+}
 
 #News Service
 docker pull 2charm/news_service
 docker run -d --network service_network --name news_service \
--p 80:80 \
 -e ADDR=$NEWSADDR \
+-e IDENTITYKEY=$IDENTITYKEY \
 -e DSN=$DSN \
 -e APIKEY=$APIKEY \
-2charm/news_service
\ No newline at end of file
+2charm/news_service
+
+#Wait for the news service to report ready
+wait_ready news_service wget -q -O /dev/null http://localhost$NEWSADDR/readyz